@@ -0,0 +1,250 @@
+package compression
+
+import (
+	"fmt"
+
+	"github.com/ElwinCabrera/go-compression/lossless/ans"
+	arithmeticcoding "github.com/ElwinCabrera/go-compression/lossless/arithmetic_coding"
+	"github.com/ElwinCabrera/go-compression/lossless/huffman"
+	"github.com/ElwinCabrera/go-compression/lossless/lz77"
+	"github.com/ElwinCabrera/go-compression/lossless/run_length"
+)
+
+// The adapters below wrap the existing packages without changing their output, so data compressed through a Codec can
+// still be decompressed by calling the package directly and vice versa.
+// Empty input is handled here since none of the underlying packages can serialize an empty symbol table
+
+type HuffmanCodec struct{}
+
+func (HuffmanCodec) Name() string { return "huffman" }
+func (HuffmanCodec) ID() CodecID  { return CodecHuffman }
+
+func (HuffmanCodec) Compress(src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return []byte{}, nil
+	}
+	//canCompress only tells us the output ended up bigger than the input, the output is still valid
+	compressedData, _ := huffman.Compress(&src)
+	return compressedData, nil
+}
+
+func (c HuffmanCodec) Decompress(src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return []byte{}, nil
+	}
+	return decodeGuard(c.Name(), func() []byte {
+		return *huffman.Decompress(&src)
+	})
+}
+
+// CanonicalHuffmanCodec only sends the code lengths instead of the whole code table, which makes a big difference for
+// small inputs
+type CanonicalHuffmanCodec struct{}
+
+func (CanonicalHuffmanCodec) Name() string { return "huffman-canonical" }
+func (CanonicalHuffmanCodec) ID() CodecID  { return CodecCanonicalHuffman }
+
+func (CanonicalHuffmanCodec) Compress(src []byte) ([]byte, error) {
+	compressedData, _ := huffman.CompressCanonical(&src)
+	return compressedData, nil
+}
+
+func (CanonicalHuffmanCodec) Decompress(src []byte) ([]byte, error) {
+	decompressedData, err := huffman.DecompressCanonical(&src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptInput, err)
+	}
+	return *decompressedData, nil
+}
+
+// AdaptiveHuffmanCodec builds the codes as it goes so there is no code table at all, which makes it the best fit for
+// small messages
+type AdaptiveHuffmanCodec struct{}
+
+func (AdaptiveHuffmanCodec) Name() string { return "huffman-adaptive" }
+func (AdaptiveHuffmanCodec) ID() CodecID  { return CodecAdaptiveHuffman }
+
+func (AdaptiveHuffmanCodec) Compress(src []byte) ([]byte, error) {
+	compressedData, _ := huffman.CompressAdaptive(&src)
+	return compressedData, nil
+}
+
+func (AdaptiveHuffmanCodec) Decompress(src []byte) ([]byte, error) {
+	decompressedData, err := huffman.DecompressAdaptive(&src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptInput, err)
+	}
+	return *decompressedData, nil
+}
+
+type ArithmeticCodec struct{}
+
+func (ArithmeticCodec) Name() string { return "arith" }
+func (ArithmeticCodec) ID() CodecID  { return CodecArithmetic }
+
+func (ArithmeticCodec) Compress(src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return []byte{}, nil
+	}
+	compressedData, _ := arithmeticcoding.Compress(&src)
+	return compressedData, nil
+}
+
+func (c ArithmeticCodec) Decompress(src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return []byte{}, nil
+	}
+	decodedData, ok := arithmeticcoding.Decompress(&src)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: bad frequency table or coded data", ErrCorruptInput, c.Name())
+	}
+	return decodedData, nil
+}
+
+// AdaptiveArithmeticCodec learns the symbol counts as it goes instead of sending a frequency table
+type AdaptiveArithmeticCodec struct{}
+
+func (AdaptiveArithmeticCodec) Name() string { return "arith-adaptive" }
+func (AdaptiveArithmeticCodec) ID() CodecID  { return CodecAdaptiveArithmetic }
+
+func (AdaptiveArithmeticCodec) Compress(src []byte) ([]byte, error) {
+	compressedData, _ := arithmeticcoding.CompressAdaptive(&src)
+	return compressedData, nil
+}
+
+func (c AdaptiveArithmeticCodec) Decompress(src []byte) ([]byte, error) {
+	decompressedData, ok := arithmeticcoding.DecompressAdaptive(&src)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: no end symbol before the end of the data", ErrCorruptInput, c.Name())
+	}
+	return decompressedData, nil
+}
+
+// PPMCodec predicts every byte from the bytes right before it (order DefaultPPMOrder contexts), which does a lot
+// better than order-0 coding on text
+type PPMCodec struct{}
+
+func (PPMCodec) Name() string { return "ppm" }
+func (PPMCodec) ID() CodecID  { return CodecPPM }
+
+func (PPMCodec) Compress(src []byte) ([]byte, error) {
+	compressedData, _ := arithmeticcoding.CompressPPM(&src, arithmeticcoding.DefaultPPMOrder)
+	return compressedData, nil
+}
+
+func (c PPMCodec) Decompress(src []byte) ([]byte, error) {
+	decompressedData, ok := arithmeticcoding.DecompressPPM(&src)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: bad order or no end symbol before the end of the data", ErrCorruptInput, c.Name())
+	}
+	return decompressedData, nil
+}
+
+// RANSCodec uses the same kind of static frequency table as ArithmeticCodec but codes with rANS, which is a lot
+// faster to decode
+type RANSCodec struct{}
+
+func (RANSCodec) Name() string { return "rans" }
+func (RANSCodec) ID() CodecID  { return CodecRANS }
+
+func (RANSCodec) Compress(src []byte) ([]byte, error) {
+	compressedData, _ := ans.CompressRANS(&src)
+	return compressedData, nil
+}
+
+func (c RANSCodec) Decompress(src []byte) ([]byte, error) {
+	return c.decompressWithLimit(src, ans.DefaultMaxDecompressedLen)
+}
+
+func (RANSCodec) decompressWithLimit(src []byte, maxLen uint64) ([]byte, error) {
+	decompressedData, err := ans.DecompressRANSWithLimit(&src, maxLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptInput, err)
+	}
+	return *decompressedData, nil
+}
+
+// TANSCodec is RANSCodec with the arithmetic turned into lookup tables (tANS/FSE)
+type TANSCodec struct{}
+
+func (TANSCodec) Name() string { return "tans" }
+func (TANSCodec) ID() CodecID  { return CodecTANS }
+
+func (TANSCodec) Compress(src []byte) ([]byte, error) {
+	compressedData, _ := ans.CompressTANS(&src)
+	return compressedData, nil
+}
+
+func (c TANSCodec) Decompress(src []byte) ([]byte, error) {
+	return c.decompressWithLimit(src, ans.DefaultMaxDecompressedLen)
+}
+
+func (TANSCodec) decompressWithLimit(src []byte, maxLen uint64) ([]byte, error) {
+	decompressedData, err := ans.DecompressTANSWithLimit(&src, maxLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptInput, err)
+	}
+	return *decompressedData, nil
+}
+
+type RunLengthCodec struct{}
+
+func (RunLengthCodec) Name() string { return "rle" }
+func (RunLengthCodec) ID() CodecID  { return CodecRunLength }
+
+func (RunLengthCodec) Compress(src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return []byte{}, nil
+	}
+	return run_length.RunLengthEncode(src), nil
+}
+
+func (c RunLengthCodec) Decompress(src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return []byte{}, nil
+	}
+	if len(src)%2 != 0 {
+		return nil, ErrCorruptInput
+	}
+	return decodeGuard(c.Name(), func() []byte {
+		return run_length.RunLengthDecode(src)
+	})
+}
+
+// PackBitsCodec is run-length coding that copies bytes that don't repeat as they are, so unlike RunLengthCodec it can
+// only grow the data by 1 byte in 128
+type PackBitsCodec struct{}
+
+func (PackBitsCodec) Name() string { return "rle-packbits" }
+func (PackBitsCodec) ID() CodecID  { return CodecPackBits }
+
+func (PackBitsCodec) Compress(src []byte) ([]byte, error) {
+	return run_length.PackBitsEncode(src), nil
+}
+
+func (PackBitsCodec) Decompress(src []byte) ([]byte, error) {
+	decompressedData, err := run_length.PackBitsDecode(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptInput, err)
+	}
+	return decompressedData, nil
+}
+
+// LZ77Codec replaces repeated phrases with matches into the last 32KB and huffman codes what's left
+type LZ77Codec struct{}
+
+func (LZ77Codec) Name() string { return "lz77" }
+func (LZ77Codec) ID() CodecID  { return CodecLZ77 }
+
+func (LZ77Codec) Compress(src []byte) ([]byte, error) {
+	compressedData, _ := lz77.Compress(&src)
+	return compressedData, nil
+}
+
+func (LZ77Codec) Decompress(src []byte) ([]byte, error) {
+	decompressedData, err := lz77.Decompress(&src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptInput, err)
+	}
+	return *decompressedData, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	compression "github.com/ElwinCabrera/go-compression"
+	"github.com/ElwinCabrera/go-compression/compressionutils"
+)
+
+func runCompress(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("compress", stderr)
+	algo := fs.String("algo", "huffman", "codec to compress with (huffman, huffman-canonical, huffman-adaptive, arith, arith-adaptive, ppm, rans, tans, rle, rle-packbits or lz77)")
+	output := fs.String("o", "-", "output file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := compression.Get(*algo)
+	if err != nil {
+		return err
+	}
+	data, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+	compressedData, err := compression.Compress(c, data)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, stdout, compressedData)
+}
+
+func runDecompress(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("decompress", stderr)
+	output := fs.String("o", "-", "output file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+	decompressedData, err := compression.DecompressAuto(data)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, stdout, decompressedData)
+}
+
+func runAnalyze(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("analyze", stderr)
+	top := fs.Int("top", 16, "number of most frequent symbols to show in the histogram (0 shows all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		fmt.Fprintln(stdout, "Size: 0 bytes")
+		return nil
+	}
+
+	freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+	probMap := compressionutils.GetSymbolProbMapFromFreqMap(freqMap, len(data))
+	entropy := compressionutils.CalculateEntropyFromProbabilities(*probMap)
+
+	fmt.Fprintf(stdout, "Size: %v bytes\n", len(data))
+	fmt.Fprintf(stdout, "Unique symbols: %v\n", len(*freqMap))
+	fmt.Fprintf(stdout, "Entropy: %.4f bits/symbol\n", entropy)
+	fmt.Fprintf(stdout, "Smallest possible order-0 size (without any tables): %.0f bytes (%.2f%% of original)\n",
+		entropy*float64(len(data))/8, entropy/8*100)
+
+	// sort by frequency, ties broken by symbol so the output is stable
+	symbols := make([]uint16, 0, len(*freqMap))
+	for sym := range *freqMap {
+		symbols = append(symbols, sym)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		fi, fj := (*freqMap)[symbols[i]], (*freqMap)[symbols[j]]
+		if fi != fj {
+			return fi > fj
+		}
+		return symbols[i] < symbols[j]
+	})
+	if *top > 0 && *top < len(symbols) {
+		symbols = symbols[:*top]
+	}
+
+	const barWidth = 40
+	maxFreq := (*freqMap)[symbols[0]]
+	fmt.Fprintln(stdout, "\nSymbol histogram:")
+	for _, sym := range symbols {
+		freq := (*freqMap)[sym]
+		bar := strings.Repeat("#", max(1, int(freq*barWidth/maxFreq)))
+		fmt.Fprintf(stdout, "  %-6s %10d %6.2f%% %s\n", symbolString(byte(sym)), freq, (*probMap)[sym]*100, bar)
+	}
+	return nil
+}
+
+func runBench(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("bench", stderr)
+	algo := fs.String("algo", "", "only benchmark this codec (default all)")
+	iterations := fs.Int("n", 1, "number of times to compress and decompress the input")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *iterations < 1 {
+		return fmt.Errorf("bench: -n must be at least 1")
+	}
+
+	codecs := compression.Codecs()
+	if *algo != "" {
+		c, err := compression.Get(*algo)
+		if err != nil {
+			return err
+		}
+		codecs = []compression.Codec{c}
+	}
+	data, err := readInput(fs, stdin)
+	if err != nil {
+		return err
+	}
+
+	// the codec column is as wide as the longest name so names like huffman-canonical don't push the other columns over
+	nameWidth := len("codec")
+	for _, c := range codecs {
+		nameWidth = max(nameWidth, len(c.Name()))
+	}
+	fmt.Fprintf(stdout, "%-*s %12s %12s %8s %14s %14s\n", nameWidth, "codec", "original", "compressed", "ratio", "compress", "decompress")
+	for _, c := range codecs {
+		var compressedData, decompressedData []byte
+		compressStart := time.Now()
+		for i := 0; i < *iterations; i++ {
+			if compressedData, err = c.Compress(data); err != nil {
+				return fmt.Errorf("%v: %w", c.Name(), err)
+			}
+		}
+		compressTime := time.Since(compressStart) / time.Duration(*iterations)
+
+		decompressStart := time.Now()
+		for i := 0; i < *iterations; i++ {
+			if decompressedData, err = c.Decompress(compressedData); err != nil {
+				return fmt.Errorf("%v: %w", c.Name(), err)
+			}
+		}
+		decompressTime := time.Since(decompressStart) / time.Duration(*iterations)
+
+		if !bytes.Equal(data, decompressedData) {
+			return fmt.Errorf("%v: decompressed data does not match the input", c.Name())
+		}
+
+		ratio := 0.0
+		if len(compressedData) > 0 {
+			ratio = float64(len(data)) / float64(len(compressedData))
+		}
+		fmt.Fprintf(stdout, "%-*s %12d %12d %8.3f %14s %14s\n", nameWidth, c.Name(), len(data), len(compressedData), ratio,
+			throughput(len(data), compressTime), throughput(len(data), decompressTime))
+	}
+	return nil
+}
+
+// Helpers
+
+func symbolString(sym byte) string {
+	if sym >= 0x21 && sym < 0x7F {
+		return fmt.Sprintf("'%c'", sym)
+	}
+	return fmt.Sprintf("0x%02X", sym)
+}
+
+func throughput(numBytes int, d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f MB/s", float64(numBytes)/d.Seconds()/(1<<20))
+}
@@ -0,0 +1,86 @@
+// Command gocompress compresses, decompresses and analyzes data with the codecs in this module.
+//
+//	gocompress compress   [-algo huffman|huffman-canonical|huffman-adaptive|arith|arith-adaptive|ppm|rans|tans|rle|rle-packbits|lz77] [-o output] [input]
+//	gocompress decompress [-o output] [input]
+//	gocompress analyze    [-top N] [input]
+//	gocompress bench      [-algo name] [-n iterations] [input]
+//
+// When no input (or "-") is given it is read from stdin, and when no output (or "-") is given it is written to stdout.
+// Compressed output is wrapped in a container so decompress can figure out which codec to use on its own.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "gocompress: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return errors.New("no subcommand given")
+	}
+
+	cmdArgs := args[1:]
+	switch args[0] {
+	case "compress":
+		return runCompress(cmdArgs, stdin, stdout, stderr)
+	case "decompress":
+		return runDecompress(cmdArgs, stdin, stdout, stderr)
+	case "analyze":
+		return runAnalyze(cmdArgs, stdin, stdout, stderr)
+	case "bench":
+		return runBench(cmdArgs, stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		printUsage(stdout)
+		return nil
+	default:
+		printUsage(stderr)
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	fmt.Fprintln(w, "  gocompress compress   [-algo huffman|huffman-canonical|huffman-adaptive|arith|arith-adaptive|ppm|rans|tans|rle|rle-packbits|lz77] [-o output] [input]")
+	fmt.Fprintln(w, "  gocompress decompress [-o output] [input]")
+	fmt.Fprintln(w, "  gocompress analyze    [-top N] [input]")
+	fmt.Fprintln(w, "  gocompress bench      [-algo name] [-n iterations] [input]")
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// readInput reads the whole input named by the first positional argument, or stdin if there is none
+func readInput(fs *flag.FlagSet, stdin io.Reader) ([]byte, error) {
+	if fs.NArg() > 1 {
+		return nil, fmt.Errorf("%v: expected at most one input but got %v", fs.Name(), fs.NArg())
+	}
+	name := fs.Arg(0)
+	if name == "" || name == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func writeOutput(name string, stdout io.Writer, data []byte) error {
+	if name == "" || name == "-" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(name, data, 0644)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	for _, algo := range []string{"huffman", "arith", "rle"} {
+		var compressed, decompressed bytes.Buffer
+		if err := run([]string{"compress", "-algo", algo}, bytes.NewReader(data), &compressed, io.Discard); err != nil {
+			t.Fatalf("%v: compress failed: %v", algo, err)
+		}
+		if err := run([]string{"decompress"}, &compressed, &decompressed, io.Discard); err != nil {
+			t.Fatalf("%v: decompress failed: %v", algo, err)
+		}
+		if !bytes.Equal(data, decompressed.Bytes()) {
+			t.Fatalf("%v: decompressed output does not match the input", algo)
+		}
+	}
+}
+
+func TestAnalyzeAndBench(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+
+	var out bytes.Buffer
+	if err := run([]string{"analyze", "-top", "3"}, bytes.NewReader(data), &out, io.Discard); err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Entropy:") || !strings.Contains(out.String(), "'D'") {
+		t.Fatalf("analyze output is missing the entropy or the most frequent symbol:\n%v", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"bench"}, bytes.NewReader(data), &out, io.Discard); err != nil {
+		t.Fatalf("bench failed: %v", err)
+	}
+	for _, algo := range []string{"huffman", "arith", "rle"} {
+		if !strings.Contains(out.String(), algo) {
+			t.Fatalf("bench output is missing %v:\n%v", algo, out.String())
+		}
+	}
+	// the original size column has to line up no matter how long the codec name is
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	originalEnd := strings.Index(lines[0], "original") + len("original")
+	for _, line := range lines[1:] {
+		if fields := strings.Fields(line); !strings.HasSuffix(line[:originalEnd], " "+fields[1]) {
+			t.Fatalf("bench columns don't line up:\n%v", out.String())
+		}
+	}
+}
+
+func TestUnknownInput(t *testing.T) {
+	if err := run([]string{"compress", "-algo", "zip"}, strings.NewReader("data"), io.Discard, io.Discard); err == nil {
+		t.Fatalf("expected an error for an unknown codec")
+	}
+	if err := run([]string{"decompress"}, strings.NewReader("not compressed"), io.Discard, io.Discard); err == nil {
+		t.Fatalf("expected an error when decompressing data that is not a container")
+	}
+	if err := run([]string{"shrink"}, strings.NewReader(""), io.Discard, io.Discard); err == nil {
+		t.Fatalf("expected an error for an unknown subcommand")
+	}
+}
@@ -0,0 +1,67 @@
+package compression
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CodecID is the numeric identifier of a codec. It is small enough to be stored in a single byte
+// so it can be embedded in headers of compressed data
+type CodecID uint8
+
+const (
+	CodecUnknown CodecID = iota
+	CodecHuffman
+	CodecArithmetic
+	CodecRunLength
+	CodecCanonicalHuffman
+	CodecAdaptiveHuffman
+	CodecAdaptiveArithmetic
+	CodecPPM
+	CodecRANS
+	CodecTANS
+	CodecPackBits
+	CodecLZ77
+)
+
+var (
+	ErrUnknownCodec   = errors.New("compression: unknown codec")
+	ErrDuplicateCodec = errors.New("compression: codec already registered")
+	ErrCorruptInput   = errors.New("compression: corrupt input")
+)
+
+// Codec is the common call shape for every compression algorithm in this module, so callers can swap one algorithm
+// for another without caring which package it lives in
+type Codec interface {
+	Name() string
+	ID() CodecID
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+// limitedDecompressor is for codecs that size their output from a count stored in the data, which a corrupt blob can
+// set to anything. DecompressAuto hands them the original length from the container so they never decode past it
+type limitedDecompressor interface {
+	decompressWithLimit(src []byte, maxLen uint64) ([]byte, error)
+}
+
+func (id CodecID) String() string {
+	if c, err := GetByID(id); err == nil {
+		return c.Name()
+	}
+	return fmt.Sprintf("CodecID(%d)", uint8(id))
+}
+
+// Helpers
+
+// The underlying packages index straight into the compressed data and panic when it is truncated or not what they expect.
+// decodeGuard turns those panics into an ErrCorruptInput so a bad blob can't take down the caller
+func decodeGuard(codecName string, decodeFunc func() []byte) (decoded []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			decoded = nil
+			err = fmt.Errorf("%w: %s: %v", ErrCorruptInput, codecName, r)
+		}
+	}()
+	return decodeFunc(), nil
+}
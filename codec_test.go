@@ -0,0 +1,77 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+func getSmallTestData() [][]byte {
+	return [][]byte{
+		{},
+		{'A'},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		{'A', ' ', 'S', 'A', 'D', ' ', 'S', 'A', 'L', 'A', 'D'},
+		[]byte("WWWWWWWWWWWWBWWWWWWWWWWWWBBBWWWWWWWWWWWWWWWWWWWWWWWWBWWWWWWWWWWWWWW"),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(4000, 2),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(4000, 256),
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, c := range Codecs() {
+		for i, data := range getSmallTestData() {
+			compressedData, err := c.Compress(data)
+			if err != nil {
+				t.Fatalf("%v: Compress failed for dataset #%v: %v", c.Name(), i, err)
+			}
+			decompressedData, err := c.Decompress(compressedData)
+			if err != nil {
+				t.Fatalf("%v: Decompress failed for dataset #%v: %v", c.Name(), i, err)
+			}
+			if !bytes.Equal(data, decompressedData) {
+				t.Fatalf("%v: decompressed data does not match original data for dataset #%v", c.Name(), i)
+			}
+		}
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	for _, name := range []string{"huffman", "arith", "rle", "HUFFMAN"} {
+		c, err := Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", name, err)
+		}
+		byID, err := GetByID(c.ID())
+		if err != nil || byID.Name() != c.Name() {
+			t.Fatalf("GetByID(%v) returned %v, %v but expected codec %v", c.ID(), byID, err, c.Name())
+		}
+	}
+
+	if _, err := Get("zstd"); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("expected ErrUnknownCodec for an unregistered name but got %v", err)
+	}
+	if _, err := GetByID(CodecUnknown); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("expected ErrUnknownCodec for CodecUnknown but got %v", err)
+	}
+	if err := Register(HuffmanCodec{}); !errors.Is(err, ErrDuplicateCodec) {
+		t.Fatalf("expected ErrDuplicateCodec when registering huffman twice but got %v", err)
+	}
+}
+
+func TestDecompressCorruptInput(t *testing.T) {
+	if _, err := (RunLengthCodec{}).Decompress([]byte{3, 'A', 2}); !errors.Is(err, ErrCorruptInput) {
+		t.Fatalf("expected ErrCorruptInput for odd length run length data but got %v", err)
+	}
+	if _, err := (HuffmanCodec{}).Decompress([]byte{'A'}); !errors.Is(err, ErrCorruptInput) {
+		t.Fatalf("expected ErrCorruptInput for truncated huffman data but got %v", err)
+	}
+
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	compressedData, _ := ArithmeticCodec{}.Compress(data)
+	if _, err := (ArithmeticCodec{}).Decompress(compressedData[:len(compressedData)-4]); !errors.Is(err, ErrCorruptInput) {
+		t.Fatalf("expected ErrCorruptInput for truncated arithmetic data but got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package compressionutils
+
+import "math/bits"
+
+// FenwickTree (binary indexed tree) keeps a count for every symbol 0..n-1 and answers "what do the counts before
+// symbol i add up to" and "which symbol does cumulative count c fall in" in O(log n), while still letting counts
+// change in O(log n). That is exactly what an adaptive arithmetic coder needs for every symbol it codes.
+//
+// tree[i] (1-based) holds the sum of the i & -i counts that end at symbol i-1.
+type FenwickTree struct {
+	tree []uint64
+}
+
+func NewFenwickTree(size int) *FenwickTree {
+	return &FenwickTree{tree: make([]uint64, size+1)}
+}
+
+// NewFenwickTreeFromCounts builds the tree in O(n) instead of adding the counts one at a time
+func NewFenwickTreeFromCounts(counts []uint64) *FenwickTree {
+	ft := NewFenwickTree(len(counts))
+	for i, count := range counts {
+		ft.tree[i+1] += count
+		if parent := i + 1 + (i+1)&-(i+1); parent < len(ft.tree) {
+			ft.tree[parent] += ft.tree[i+1]
+		}
+	}
+	return ft
+}
+
+func (ft *FenwickTree) Len() int {
+	return len(ft.tree) - 1
+}
+
+// Add changes the count of sym by delta. The count can't go below 0
+func (ft *FenwickTree) Add(sym int, delta int64) {
+	for i := sym + 1; i < len(ft.tree); i += i & -i {
+		ft.tree[i] += uint64(delta)
+	}
+}
+
+// PrefixSum returns the sum of the counts of the symbols before sym, so PrefixSum(Len()) is the total
+func (ft *FenwickTree) PrefixSum(sym int) uint64 {
+	sum := uint64(0)
+	for i := sym; i > 0; i -= i & -i {
+		sum += ft.tree[i]
+	}
+	return sum
+}
+
+func (ft *FenwickTree) Total() uint64 {
+	return ft.PrefixSum(ft.Len())
+}
+
+func (ft *FenwickTree) Get(sym int) uint64 {
+	return ft.PrefixSum(sym+1) - ft.PrefixSum(sym)
+}
+
+// Find returns the symbol whose range [PrefixSum(sym), PrefixSum(sym+1)) has count in it, or Len() if count is not
+// below the total. It walks down from the biggest power of two instead of doing a binary search over PrefixSum, which
+// keeps it at O(log n)
+func (ft *FenwickTree) Find(count uint64) int {
+	pos := 0
+	for step := 1 << bits.Len(uint(ft.Len())) >> 1; step > 0; step >>= 1 {
+		if next := pos + step; next < len(ft.tree) && ft.tree[next] <= count {
+			pos = next
+			count -= ft.tree[next]
+		}
+	}
+	return pos
+}
@@ -0,0 +1,59 @@
+package compressionutils
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFenwickTreeMatchesPlainCounts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, size := range []int{1, 2, 3, 7, 8, 257, 1000} {
+		counts := make([]uint64, size)
+		for i := range counts {
+			// leave some symbols at 0, Find has to skip over them
+			if rnd.Intn(4) != 0 {
+				counts[i] = uint64(rnd.Intn(50))
+			}
+		}
+		ft := NewFenwickTreeFromCounts(counts)
+
+		for op := 0; op < 2000; op++ {
+			sym := rnd.Intn(size)
+			delta := int64(rnd.Intn(20)) - int64(min(counts[sym], 10))
+			counts[sym] += uint64(delta)
+			ft.Add(sym, delta)
+
+			total := uint64(0)
+			for i, count := range counts {
+				if prefix := ft.PrefixSum(i); prefix != total {
+					t.Fatalf("size %v: PrefixSum(%v) is %v but the counts add up to %v", size, i, prefix, total)
+				}
+				if got := ft.Get(i); got != count {
+					t.Fatalf("size %v: Get(%v) is %v but expected %v", size, i, got, count)
+				}
+				total += count
+			}
+			if ft.Total() != total {
+				t.Fatalf("size %v: Total() is %v but expected %v", size, ft.Total(), total)
+			}
+
+			if total > 0 {
+				count := uint64(rnd.Int63n(int64(total)))
+				found := ft.Find(count)
+				if found >= size || ft.PrefixSum(found) > count || count >= ft.PrefixSum(found+1) {
+					t.Fatalf("size %v: Find(%v) returned %v", size, count, found)
+				}
+			}
+			if found := ft.Find(total); found != size {
+				t.Fatalf("size %v: Find of the total returned %v instead of %v", size, found, size)
+			}
+		}
+	}
+}
+
+func TestFenwickTreeEmpty(t *testing.T) {
+	ft := NewFenwickTree(0)
+	if ft.Total() != 0 || ft.Find(0) != 0 {
+		t.Fatalf("expected an empty tree to have a total of 0 and Find to return 0")
+	}
+}
@@ -0,0 +1,72 @@
+package compressionutils
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// NormalizeFrequencies scales the frequencies so they add up to exactly targetTotal while every symbol that has a
+// frequency keeps at least 1 (symbols with a frequency of 0 are left out). Each count is rounded down first and the
+// leftovers go to the biggest remainders, anything over the target after bumping the tiny symbols up to 1 is taken
+// back from wherever it costs the fewest bits. It returns false when there's nothing to normalize or when targetTotal
+// is too small to give every symbol a count.
+func NormalizeFrequencies(freq *map[uint16]uint64, targetTotal uint64) (*map[uint16]uint64, bool) {
+	normFreq := make(map[uint16]uint64)
+	total := uint64(0)
+	for _, count := range *freq {
+		total += count
+	}
+
+	type remainder struct {
+		sym uint16
+		rem uint64
+	}
+	remainders := make([]remainder, 0, len(*freq))
+	sum := uint64(0)
+	for sym, count := range *freq {
+		if count == 0 {
+			continue
+		}
+		hi, lo := bits.Mul64(count, targetTotal)
+		quo, rem := bits.Div64(hi, lo, total)
+		if quo == 0 {
+			quo, rem = 1, 0
+		}
+		normFreq[sym] = quo
+		sum += quo
+		remainders = append(remainders, remainder{sym, rem})
+	}
+	if len(remainders) == 0 || uint64(len(remainders)) > targetTotal {
+		return nil, false
+	}
+
+	// map order is random, the ties have to be broken the same way every time
+	sort.Slice(remainders, func(i, j int) bool {
+		if remainders[i].rem != remainders[j].rem {
+			return remainders[i].rem > remainders[j].rem
+		}
+		return remainders[i].sym < remainders[j].sym
+	})
+	for i := 0; sum < targetTotal; i = (i + 1) % len(remainders) {
+		normFreq[remainders[i].sym]++
+		sum++
+	}
+
+	// taking one away from a count of n costs freq * log2(n / (n-1)) bits, always take it from where that is cheapest
+	for sum > targetTotal {
+		cheapest, cheapestCost := -1, math.Inf(1)
+		for i, r := range remainders {
+			n := float64(normFreq[r.sym])
+			if n <= 1 {
+				continue
+			}
+			if cost := float64((*freq)[r.sym]) * math.Log2(n/(n-1)); cost < cheapestCost {
+				cheapest, cheapestCost = i, cost
+			}
+		}
+		normFreq[remainders[cheapest].sym]--
+		sum--
+	}
+	return &normFreq, true
+}
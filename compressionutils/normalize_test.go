@@ -0,0 +1,71 @@
+package compressionutils
+
+import (
+	"maps"
+	"math/rand"
+	"testing"
+)
+
+func TestNormalizeFrequencies(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, numSyms := range []int{1, 2, 26, 200, 256} {
+		freq := make(map[uint16]uint64)
+		for sym := 0; sym < numSyms; sym++ {
+			// a few huge counts next to a lot of 1s so plenty of symbols have to be bumped up
+			freq[uint16(sym)] = 1
+			if rnd.Intn(8) == 0 {
+				freq[uint16(sym)] = uint64(rnd.Int63n(1 << 40))
+			}
+		}
+		freq[300] = 0
+
+		for _, targetTotal := range []uint64{uint64(numSyms), 256, 4096, 1 << 15, 1 << 50} {
+			if targetTotal < uint64(numSyms) {
+				continue
+			}
+			normFreq, ok := NormalizeFrequencies(&freq, targetTotal)
+			if !ok {
+				t.Fatalf("%v symbols: could not normalize to %v", numSyms, targetTotal)
+			}
+			total := uint64(0)
+			for sym, count := range *normFreq {
+				if count == 0 {
+					t.Fatalf("%v symbols: symbol %v was normalized to 0", numSyms, sym)
+				}
+				total += count
+			}
+			if total != targetTotal {
+				t.Fatalf("%v symbols: normalized counts add up to %v instead of %v", numSyms, total, targetTotal)
+			}
+			if _, ok := (*normFreq)[300]; ok || len(*normFreq) != numSyms {
+				t.Fatalf("%v symbols: normalized table has %v symbols", numSyms, len(*normFreq))
+			}
+			// map order is random, the result can't depend on it
+			if again, _ := NormalizeFrequencies(&freq, targetTotal); !maps.Equal(*normFreq, *again) {
+				t.Fatalf("%v symbols: normalizing to %v twice gave different counts", numSyms, targetTotal)
+			}
+		}
+
+		if numSyms > 1 {
+			if _, ok := NormalizeFrequencies(&freq, uint64(numSyms-1)); ok {
+				t.Fatalf("expected %v symbols to not fit in a total of %v", numSyms, numSyms-1)
+			}
+		}
+	}
+
+	if _, ok := NormalizeFrequencies(&map[uint16]uint64{'A': 0}, 4096); ok {
+		t.Fatalf("expected a table without any counts to not normalize")
+	}
+}
+
+func TestNormalizeFrequenciesKeepsProportions(t *testing.T) {
+	freq := map[uint16]uint64{'A': 500, 'B': 300, 'C': 150, 'D': 50}
+	normFreq, _ := NormalizeFrequencies(&freq, 1000)
+	if !maps.Equal(freq, *normFreq) {
+		t.Fatalf("counts that already add up to the target changed to %v", *normFreq)
+	}
+	normFreq, _ = NormalizeFrequencies(&freq, 100)
+	if expected := (map[uint16]uint64{'A': 50, 'B': 30, 'C': 15, 'D': 5}); !maps.Equal(expected, *normFreq) {
+		t.Fatalf("expected %v but got %v", expected, *normFreq)
+	}
+}
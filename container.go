@@ -0,0 +1,138 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// A container wraps the output of any codec in a fixed size header so the data says which codec produced it and can
+// be checked for corruption after decompressing:
+//	<magic><version><codec_id><flags><original_len><crc32 of original data><codec output...>
+//	4 bytes  1 byte   1 byte   1 byte  8 bytes (BE)       4 bytes (BE)          X bytes
+
+var containerMagic = [4]byte{'G', 'C', 'M', 'P'}
+
+const (
+	ContainerVersion = 1
+	HeaderLen        = 4 + 1 + 1 + 1 + 8 + 4
+)
+
+// Header flags
+const (
+	// FlagStored means the payload is the original data as is because the codec could not make it any smaller
+	FlagStored uint8 = 1 << iota
+)
+
+var (
+	ErrNotContainer       = errors.New("compression: data is not a compressed container")
+	ErrUnsupportedVersion = errors.New("compression: unsupported container version")
+	ErrChecksumMismatch   = errors.New("compression: checksum mismatch")
+)
+
+type Header struct {
+	Version     uint8
+	Codec       CodecID
+	Flags       uint8
+	OriginalLen uint64
+	Checksum    uint32
+}
+
+// Compress runs src through the codec and wraps the result in a container. If the codec output ends up bigger than
+// the original data, the original data is stored instead and the header gets FlagStored
+func Compress(c Codec, src []byte) ([]byte, error) {
+	payload, err := c.Compress(src)
+	if err != nil {
+		return nil, err
+	}
+
+	h := Header{
+		Version:     ContainerVersion,
+		Codec:       c.ID(),
+		OriginalLen: uint64(len(src)),
+		Checksum:    crc32.ChecksumIEEE(src),
+	}
+	if len(payload) >= len(src) {
+		h.Flags |= FlagStored
+		payload = src
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(HeaderLen + len(payload))
+	buf.Write(h.marshal())
+	buf.Write(payload)
+	return buf.Bytes(), nil
+}
+
+// DecompressAuto reads the container header, decompresses the payload with the codec it names and verifies the
+// result against the original length and checksum
+func DecompressAuto(data []byte) ([]byte, error) {
+	h, err := ReadHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	payload := data[HeaderLen:]
+
+	var decompressed []byte
+	if h.Flags&FlagStored != 0 {
+		decompressed = payload
+	} else {
+		c, err := GetByID(h.Codec)
+		if err != nil {
+			return nil, err
+		}
+		if lc, ok := c.(limitedDecompressor); ok {
+			decompressed, err = lc.decompressWithLimit(payload, h.OriginalLen)
+		} else {
+			decompressed, err = c.Decompress(payload)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if uint64(len(decompressed)) != h.OriginalLen {
+		return nil, fmt.Errorf("%w: got %v bytes but expected %v", ErrCorruptInput, len(decompressed), h.OriginalLen)
+	}
+	if crc32.ChecksumIEEE(decompressed) != h.Checksum {
+		return nil, ErrChecksumMismatch
+	}
+	return decompressed, nil
+}
+
+// ReadHeader parses and validates the header at the start of data
+func ReadHeader(data []byte) (Header, error) {
+	if len(data) < HeaderLen || !bytes.Equal(data[:4], containerMagic[:]) {
+		return Header{}, ErrNotContainer
+	}
+	h := Header{
+		Version:     data[4],
+		Codec:       CodecID(data[5]),
+		Flags:       data[6],
+		OriginalLen: binary.BigEndian.Uint64(data[7:15]),
+		Checksum:    binary.BigEndian.Uint32(data[15:19]),
+	}
+	if h.Version != ContainerVersion {
+		return Header{}, fmt.Errorf("%w: %v", ErrUnsupportedVersion, h.Version)
+	}
+	return h, nil
+}
+
+// IsContainer reports whether data starts with a container header
+func IsContainer(data []byte) bool {
+	_, err := ReadHeader(data)
+	return err == nil
+}
+
+func (h Header) marshal() []byte {
+	buf := make([]byte, HeaderLen)
+	copy(buf, containerMagic[:])
+	buf[4] = h.Version
+	buf[5] = byte(h.Codec)
+	buf[6] = h.Flags
+	binary.BigEndian.PutUint64(buf[7:15], h.OriginalLen)
+	binary.BigEndian.PutUint32(buf[15:19], h.Checksum)
+	return buf
+}
@@ -0,0 +1,90 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestContainerRoundTrip(t *testing.T) {
+	for _, c := range Codecs() {
+		for i, data := range getSmallTestData() {
+			container, err := Compress(c, data)
+			if err != nil {
+				t.Fatalf("%v: Compress failed for dataset #%v: %v", c.Name(), i, err)
+			}
+
+			h, err := ReadHeader(container)
+			if err != nil {
+				t.Fatalf("%v: ReadHeader failed for dataset #%v: %v", c.Name(), i, err)
+			}
+			if h.Codec != c.ID() || h.OriginalLen != uint64(len(data)) {
+				t.Fatalf("%v: unexpected header for dataset #%v: %+v", c.Name(), i, h)
+			}
+			if len(container) > HeaderLen+len(data) {
+				t.Fatalf("%v: container for dataset #%v is %v bytes, more than the header plus the original data", c.Name(), i, len(container))
+			}
+
+			decompressed, err := DecompressAuto(container)
+			if err != nil {
+				t.Fatalf("%v: DecompressAuto failed for dataset #%v: %v", c.Name(), i, err)
+			}
+			if !bytes.Equal(data, decompressed) {
+				t.Fatalf("%v: decompressed data does not match original data for dataset #%v", c.Name(), i)
+			}
+		}
+	}
+}
+
+func TestContainerCorruption(t *testing.T) {
+	data := []byte("WWWWWWWWWWWWBWWWWWWWWWWWWBBBWWWWWWWWWWWWWWWWWWWWWWWWBWWWWWWWWWWWWWW")
+	container, err := Compress(RunLengthCodec{}, data)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	if _, err = DecompressAuto(data); !errors.Is(err, ErrNotContainer) {
+		t.Fatalf("expected ErrNotContainer for raw data but got %v", err)
+	}
+
+	badVersion := bytes.Clone(container)
+	badVersion[4] = ContainerVersion + 1
+	if _, err = DecompressAuto(badVersion); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion but got %v", err)
+	}
+
+	badCodec := bytes.Clone(container)
+	badCodec[5] = 0xEE
+	if _, err = DecompressAuto(badCodec); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("expected ErrUnknownCodec but got %v", err)
+	}
+
+	// flip the symbol of the first run, the length stays the same so only the checksum can catch it
+	badPayload := bytes.Clone(container)
+	badPayload[HeaderLen+1] = 'X'
+	if _, err = DecompressAuto(badPayload); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch but got %v", err)
+	}
+
+	// change the length of the first run
+	badLen := bytes.Clone(container)
+	badLen[HeaderLen]++
+	if _, err = DecompressAuto(badLen); !errors.Is(err, ErrCorruptInput) {
+		t.Fatalf("expected ErrCorruptInput but got %v", err)
+	}
+
+	// data that is all one symbol codes to just the ans header, a forged symbol count has to be stopped by the length
+	// in the container before the decoder allocates it
+	for _, c := range []Codec{RANSCodec{}, TANSCodec{}} {
+		container, err = Compress(c, bytes.Repeat([]byte{'A'}, 100))
+		if err != nil {
+			t.Fatalf("%s: Compress failed: %v", c.Name(), err)
+		}
+		forged := append(container[:HeaderLen:HeaderLen], binary.AppendUvarint(nil, 1<<40)...)
+		forged = append(forged, container[HeaderLen+1:]...)
+		if _, err = DecompressAuto(forged); !errors.Is(err, ErrCorruptInput) {
+			t.Fatalf("%s: expected ErrCorruptInput for a forged symbol count but got %v", c.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package ans
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	utils2 "github.com/ElwinCabrera/go-compression/compressionutils"
+)
+
+// ANS (asymmetric numeral systems) codes the whole message into one integer state the way arithmetic coding codes it
+// into an interval, but a symbol only takes a multiply or a table lookup and a couple of shifts, so it is a lot faster.
+// The catch is that the decoder pops symbols in the reverse order the encoder pushed them, so the encoder goes through
+// the data back to front.
+//
+// Both coders here are static and work off the same frequency table built from compressionutils.GetSymbolFrequencyMap,
+// normalized so the counts add up to a power of two (1 << tableLog). That is what lets the coders divide by the total
+// with a shift.
+//
+// Compressed data layout (the same for rANS and tANS):
+//	<num_symbols><table_log><num_used-1><symbol><freq> ... <coded data>
+//	  uvarint      1 byte     1 byte    1 byte uvarint
+// There is nothing after num_symbols when it is 0.
+
+const (
+	numByteSymbols = 256
+	// numStates is how many coders are interleaved over the data. Symbol i goes through state i % numStates so the
+	// states don't depend on each other and the cpu can work on them at the same time
+	numStates   = 4
+	minTableLog = 8
+
+	// DefaultMaxDecompressedLen is the most DecompressRANS and DecompressTANS will decode. Data that is all one symbol
+	// codes to nothing but its header however long it is, so the symbol count is the only thing saying how big the output
+	// gets and a made up one has to be stopped before it gets allocated. Use the WithLimit versions when the real length
+	// is known
+	DefaultMaxDecompressedLen = 1 << 30
+)
+
+var ErrCorruptData = errors.New("ans: corrupt data")
+
+// normalizeFrequencies scales the counts in freqMap so they add up to exactly 1 << tableLog without dropping any symbol
+// to 0, see compressionutils.NormalizeFrequencies
+func normalizeFrequencies(freqMap *map[uint16]uint64, tableLog uint) ([numByteSymbols]uint32, bool) {
+	var normFreq [numByteSymbols]uint32
+	for sym := range *freqMap {
+		if sym >= numByteSymbols {
+			return normFreq, false
+		}
+	}
+	normFreqMap, ok := utils2.NormalizeFrequencies(freqMap, uint64(1)<<tableLog)
+	if !ok {
+		return normFreq, false
+	}
+	for sym, freq := range *normFreqMap {
+		normFreq[sym] = uint32(freq)
+	}
+	return normFreq, true
+}
+
+// getNormalizedFrequencies picks the table size for data and normalizes its frequencies to it
+func getNormalizedFrequencies(data *[]byte, maxTableLog uint) ([numByteSymbols]uint32, uint) {
+	freqMap := utils2.GetSymbolFrequencyMap(data)
+	// no point in a table much bigger than the data, but every symbol needs at least one slot
+	tableLog := uint(bits.Len(uint(len(*data))))
+	tableLog = max(tableLog, uint(bits.Len(uint(len(*freqMap)-1))), minTableLog)
+	tableLog = min(tableLog, maxTableLog)
+	// can't fail, there are at most 256 byte symbols and the table always has at least that many slots
+	normFreq, _ := normalizeFrequencies(freqMap, tableLog)
+	return normFreq, tableLog
+}
+
+func serializeHeader(numSymbols int, normFreq *[numByteSymbols]uint32, tableLog uint) []byte {
+	header := binary.AppendUvarint(nil, uint64(numSymbols))
+	if numSymbols == 0 {
+		return header
+	}
+	numUsed := 0
+	for _, freq := range normFreq {
+		if freq > 0 {
+			numUsed++
+		}
+	}
+	header = append(header, byte(tableLog), byte(numUsed-1))
+	for sym, freq := range normFreq {
+		if freq > 0 {
+			header = append(header, byte(sym))
+			header = binary.AppendUvarint(header, uint64(freq))
+		}
+	}
+	return header
+}
+
+// deserializeHeader returns the number of symbols, the frequency table and how many bytes the header took up. The
+// frequencies are checked to add up to 1 << tableLog
+func deserializeHeader(data []byte, maxTableLog uint) (uint64, [numByteSymbols]uint32, uint, int, error) {
+	var normFreq [numByteSymbols]uint32
+	numSymbols, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, normFreq, 0, 0, fmt.Errorf("%w: bad symbol count", ErrCorruptData)
+	}
+	if numSymbols == 0 {
+		return 0, normFreq, 0, n, nil
+	}
+	if len(data) < n+2 {
+		return 0, normFreq, 0, 0, fmt.Errorf("%w: frequency table is truncated", ErrCorruptData)
+	}
+	tableLog := uint(data[n])
+	if tableLog < minTableLog || tableLog > maxTableLog {
+		return 0, normFreq, 0, 0, fmt.Errorf("%w: table log %v is out of range", ErrCorruptData, tableLog)
+	}
+	numUsed := int(data[n+1]) + 1
+	pos := n + 2
+	total := uint64(0)
+	for i := 0; i < numUsed; i++ {
+		if pos >= len(data) {
+			return 0, normFreq, 0, 0, fmt.Errorf("%w: frequency table is truncated", ErrCorruptData)
+		}
+		sym := data[pos]
+		freq, n := binary.Uvarint(data[pos+1:])
+		if n <= 0 || freq == 0 || freq > 1<<tableLog || normFreq[sym] != 0 {
+			return 0, normFreq, 0, 0, fmt.Errorf("%w: bad frequency for symbol %v", ErrCorruptData, sym)
+		}
+		normFreq[sym] = uint32(freq)
+		total += freq
+		pos += 1 + n
+	}
+	if total != 1<<tableLog {
+		return 0, normFreq, 0, 0, fmt.Errorf("%w: frequencies add up to %v instead of %v", ErrCorruptData, total, 1<<tableLog)
+	}
+	return numSymbols, normFreq, tableLog, pos, nil
+}
+
+// checkNumSymbols makes sure a corrupt symbol count can't make us allocate more than maxLen bytes or more than the
+// coded data could ever hold. A symbol that isn't the only one costs more than 1/M of a bit, so numBytes bytes hold
+// less than 8 * numBytes * M of them. When one symbol has the whole table it is free and maxLen is all there is to go on
+func checkNumSymbols(numSymbols uint64, normFreq *[numByteSymbols]uint32, tableLog uint, numBytes int, maxLen uint64) error {
+	if numSymbols > maxLen {
+		return fmt.Errorf("%w: %v symbols is more than the limit of %v", ErrCorruptData, numSymbols, maxLen)
+	}
+	for _, freq := range normFreq {
+		if freq == 1<<tableLog {
+			return nil
+		}
+	}
+	if numSymbols > uint64(8*numBytes)<<tableLog {
+		return fmt.Errorf("%w: %v symbols can't fit in %v bytes", ErrCorruptData, numSymbols, numBytes)
+	}
+	return nil
+}
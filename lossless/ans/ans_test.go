@@ -0,0 +1,185 @@
+package ans
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/ElwinCabrera/go-compression/compressionutils"
+	arithmeticcoding "github.com/ElwinCabrera/go-compression/lossless/arithmetic_coding"
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+type ansCoder struct {
+	name                string
+	compress            func(*[]byte) ([]byte, bool)
+	decompress          func(*[]byte) (*[]byte, error)
+	decompressWithLimit func(*[]byte, uint64) (*[]byte, error)
+}
+
+var coders = []ansCoder{
+	{"rANS", CompressRANS, DecompressRANS, DecompressRANSWithLimit},
+	{"tANS", CompressTANS, DecompressTANS, DecompressTANSWithLimit},
+}
+
+func getTestData() [][]byte {
+	return [][]byte{
+		{},
+		{'A'},
+		{0x00, 0x00, 0x00},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		{'A', ' ', 'S', 'A', 'D', ' ', 'S', 'A', 'L', 'A', 'D'},
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(1000000, 1),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 2),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 52+16+10),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 256),
+		getSkewedTestData(),
+	}
+}
+
+// a few very common symbols and a long tail of ones that only show up once or twice
+func getSkewedTestData() []byte {
+	var data []byte
+	for sym := 0; sym < 256; sym++ {
+		data = append(data, bytes.Repeat([]byte{byte(sym)}, 1+(100000>>min(sym, 20)))...)
+	}
+	return data
+}
+
+func TestCompressAndDecompress(t *testing.T) {
+	for _, coder := range coders {
+		for i, data := range getTestData() {
+			compressedData, _ := coder.compress(&data)
+			decompressedData, err := coder.decompress(&compressedData)
+			if err != nil {
+				t.Fatalf("%v: decompressing dataset #%v failed: %v", coder.name, i, err)
+			}
+			if !bytes.Equal(data, *decompressedData) {
+				t.Fatalf("%v: decompressed data does not match original data for dataset #%v", coder.name, i)
+			}
+		}
+	}
+}
+
+func TestCompressionRatio(t *testing.T) {
+	for _, data := range [][]byte{
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(200000, 26),
+		getSkewedTestData(),
+	} {
+		freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+		entropy := compressionutils.CalculateEntropyFromProbabilities(*compressionutils.GetSymbolProbMapFromFreqMap(freqMap, len(data)))
+		entropyBytes := entropy * float64(len(data)) / 8
+		for _, coder := range coders {
+			compressedData, canCompress := coder.compress(&data)
+			t.Logf("%v: %v bytes to %v, entropy bound %.0f", coder.name, len(data), len(compressedData), entropyBytes)
+			// the normalized frequencies cost a little and so does the header
+			if !canCompress || float64(len(compressedData)) > 1.01*entropyBytes+1024 {
+				t.Errorf("%v compressed %v bytes to %v, the entropy bound is %.0f", coder.name, len(data), len(compressedData), entropyBytes)
+			}
+		}
+	}
+}
+
+func TestNormalizeFrequencies(t *testing.T) {
+	data := getSkewedTestData()
+	freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+	for _, tableLog := range []uint{8, 10, 12, 15} {
+		normFreq, ok := normalizeFrequencies(freqMap, tableLog)
+		if !ok {
+			t.Fatalf("could not normalize to table log %v", tableLog)
+		}
+		total := uint32(0)
+		for sym, freq := range normFreq {
+			if freq == 0 && (*freqMap)[uint16(sym)] > 0 {
+				t.Fatalf("table log %v: symbol %v was normalized to 0", tableLog, sym)
+			}
+			total += freq
+		}
+		if total != 1<<tableLog {
+			t.Fatalf("table log %v: frequencies add up to %v", tableLog, total)
+		}
+		// the same counts always have to come out the same way or the decoder would build a different table
+		if again, _ := normalizeFrequencies(freqMap, tableLog); again != normFreq {
+			t.Fatalf("table log %v: normalizing twice gave different frequencies", tableLog)
+		}
+	}
+
+	if _, ok := normalizeFrequencies(freqMap, 7); ok {
+		t.Fatalf("expected 256 symbols to not fit in a table of 128")
+	}
+}
+
+func TestCorruptData(t *testing.T) {
+	// a flipped bit isn't caught for every input, so the data has to be the same on every run
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(rnd.Intn(52 + 16 + 10))
+	}
+	for _, coder := range coders {
+		compressedData, _ := coder.compress(&data)
+
+		truncated := compressedData[:len(compressedData)-10]
+		if _, err := coder.decompress(&truncated); !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("%v: expected ErrCorruptData for truncated data but got %v", coder.name, err)
+		}
+
+		flipped := bytes.Clone(compressedData)
+		flipped[len(flipped)/2] ^= 0x10
+		if _, err := coder.decompress(&flipped); !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("%v: expected ErrCorruptData for a flipped bit but got %v", coder.name, err)
+		}
+
+		// a huge symbol count with a normal table can't make the decoder allocate it
+		hugeCount := append([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, compressedData[2:]...)
+		if _, err := coder.decompress(&hugeCount); !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("%v: expected ErrCorruptData for a huge symbol count but got %v", coder.name, err)
+		}
+	}
+}
+
+func TestForgedSingleSymbolCount(t *testing.T) {
+	data := []byte("AAAA")
+	for _, coder := range coders {
+		compressedData, _ := coder.compress(&data)
+		// the count is the first byte, everything after it is the same for any number of A's
+		for _, forgedCount := range []uint64{1 << 62, 1 << 40, DefaultMaxDecompressedLen + 1} {
+			forged := append(binary.AppendUvarint(nil, forgedCount), compressedData[1:]...)
+			if _, err := coder.decompress(&forged); !errors.Is(err, ErrCorruptData) {
+				t.Fatalf("%v: expected ErrCorruptData for a count of %v but got %v", coder.name, forgedCount, err)
+			}
+		}
+
+		if _, err := coder.decompressWithLimit(&compressedData, uint64(len(data)-1)); !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("%v: expected ErrCorruptData with a limit below the real length but got %v", coder.name, err)
+		}
+		decompressedData, err := coder.decompressWithLimit(&compressedData, uint64(len(data)))
+		if err != nil || !bytes.Equal(*decompressedData, data) {
+			t.Fatalf("%v: round trip with the limit at the real length failed: %v", coder.name, err)
+		}
+	}
+}
+
+func benchmarkDecompress(b *testing.B, compress func(*[]byte) ([]byte, bool), decompress func(*[]byte)) {
+	data := testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(1<<20, 52+16+10)
+	compressedData, _ := compress(&data)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decompress(&compressedData)
+	}
+}
+
+func BenchmarkDecompressRANS(b *testing.B) {
+	benchmarkDecompress(b, CompressRANS, func(data *[]byte) { DecompressRANS(data) })
+}
+
+func BenchmarkDecompressTANS(b *testing.B) {
+	benchmarkDecompress(b, CompressTANS, func(data *[]byte) { DecompressTANS(data) })
+}
+
+func BenchmarkDecompressArithmetic(b *testing.B) {
+	benchmarkDecompress(b, arithmeticcoding.Compress, func(data *[]byte) { arithmeticcoding.Decompress(data) })
+}
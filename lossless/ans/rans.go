@@ -0,0 +1,135 @@
+package ans
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// rANS keeps a 32-bit state x. Pushing a symbol with frequency f and cumulative frequency c (out of M = 1 << tableLog)
+// does
+//	x = (x / f) * M + (x % f) + c
+// and the decoder gets the symbol back from the slot x % M, then undoes it with
+//	x = f * (x / M) + (x % M) - c
+// x is kept in [ransLow, ransLow << 8). Before a push the encoder writes out the low bytes of x until the push can't
+// take it past the top, and after a pop the decoder reads bytes back in until x is at least ransLow again. Since the
+// decoder reads in the opposite order the encoder wrote, the encoder's bytes are reversed once it is done.
+//
+// The encoder starts every state at ransLow, so after the last symbol is decoded every state has to be back at ransLow.
+// That gives us a free check that the data wasn't corrupted.
+
+const (
+	ransLow         = 1 << 23
+	MaxRANSTableLog = 15
+)
+
+// CompressRANS codes srcData with numStates interleaved rANS states
+func CompressRANS(srcData *[]byte) ([]byte, bool) {
+	if len(*srcData) == 0 {
+		return serializeHeader(0, nil, 0), false
+	}
+	normFreq, tableLog := getNormalizedFrequencies(srcData, MaxRANSTableLog)
+	var cumFreq [numByteSymbols]uint32
+	for sym := 1; sym < numByteSymbols; sym++ {
+		cumFreq[sym] = cumFreq[sym-1] + normFreq[sym-1]
+	}
+
+	var states [numStates]uint32
+	for i := range states {
+		states[i] = ransLow
+	}
+	// written back to front, reversed at the end
+	out := make([]byte, 0, len(*srcData)/2)
+	for i := len(*srcData) - 1; i >= 0; i-- {
+		sym := (*srcData)[i]
+		x := states[i%numStates]
+		freq := normFreq[sym]
+		xMax := ((ransLow >> tableLog) << 8) * freq
+		for x >= xMax {
+			out = append(out, byte(x))
+			x >>= 8
+		}
+		states[i%numStates] = ((x / freq) << tableLog) + (x % freq) + cumFreq[sym]
+	}
+	// the decoder reads state 0 first so it has to go out last
+	for i := numStates - 1; i >= 0; i-- {
+		x := states[i]
+		out = append(out, byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	compressedData := append(serializeHeader(len(*srcData), &normFreq, tableLog), out...)
+	return compressedData, len(compressedData) < len(*srcData)
+}
+
+func DecompressRANS(data *[]byte) (*[]byte, error) {
+	return DecompressRANSWithLimit(data, DefaultMaxDecompressedLen)
+}
+
+// DecompressRANSWithLimit fails with ErrCorruptData instead of decoding more than maxLen bytes
+func DecompressRANSWithLimit(data *[]byte, maxLen uint64) (*[]byte, error) {
+	numSymbols, normFreq, tableLog, headerLen, err := deserializeHeader(*data, MaxRANSTableLog)
+	if err != nil {
+		return nil, err
+	}
+	uncompressedData := make([]byte, 0)
+	if numSymbols == 0 {
+		return &uncompressedData, nil
+	}
+	in := (*data)[headerLen:]
+	if len(in) < 4*numStates {
+		return nil, fmt.Errorf("%w: missing the coder states", ErrCorruptData)
+	}
+	if err = checkNumSymbols(numSymbols, &normFreq, tableLog, len(in), maxLen); err != nil {
+		return nil, err
+	}
+
+	var cumFreq [numByteSymbols]uint32
+	slotToSym := make([]byte, 1<<tableLog)
+	for sym := 0; sym < numByteSymbols; sym++ {
+		if sym > 0 {
+			cumFreq[sym] = cumFreq[sym-1] + normFreq[sym-1]
+		}
+		for slot := cumFreq[sym]; slot < cumFreq[sym]+normFreq[sym]; slot++ {
+			slotToSym[slot] = byte(sym)
+		}
+	}
+
+	var states [numStates]uint32
+	for i := range states {
+		states[i] = binary.LittleEndian.Uint32(in[4*i:])
+		if states[i] < ransLow {
+			return nil, fmt.Errorf("%w: coder state %v is out of range", ErrCorruptData, i)
+		}
+	}
+	pos := 4 * numStates
+	mask := uint32(1)<<tableLog - 1
+
+	uncompressedData = make([]byte, numSymbols)
+	for i := range uncompressedData {
+		x := states[i%numStates]
+		slot := x & mask
+		sym := slotToSym[slot]
+		uncompressedData[i] = sym
+		x = normFreq[sym]*(x>>tableLog) + slot - cumFreq[sym]
+		for x < ransLow {
+			if pos >= len(in) {
+				return nil, fmt.Errorf("%w: coded data ends after %v symbols", ErrCorruptData, i)
+			}
+			x = (x << 8) | uint32(in[pos])
+			pos++
+		}
+		states[i%numStates] = x
+	}
+
+	for i, x := range states {
+		if x != ransLow {
+			return nil, fmt.Errorf("%w: coder state %v did not end where it started", ErrCorruptData, i)
+		}
+	}
+	if pos != len(in) {
+		return nil, fmt.Errorf("%w: %v bytes left over after the last symbol", ErrCorruptData, len(in)-pos)
+	}
+	return &uncompressedData, nil
+}
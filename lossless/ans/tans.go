@@ -0,0 +1,220 @@
+package ans
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// tANS (the FSE flavour) turns the rANS arithmetic into tables. There are M = 1 << tableLog states and every symbol
+// owns as many of them as its normalized frequency, spread around the table so each symbol's states are mixed in
+// with everyone else's. Decoding a state gives its symbol plus how many bits to read and what to add to them to get the
+// next state, so a symbol is one lookup and one bit read. The encoder does the opposite: it writes out the low bits of
+// its state until what is left is in [freq, 2*freq) and looks up which state that maps to.
+//
+// The encoder keeps its state as M + state so the number of bits to write can be read off the bit length. All states
+// start at 0 and are written out at the end with tableLog bits each, so the decoder starts where the encoder ended and
+// has to end at 0 just like rANS ends at ransLow.
+//
+// The bits are written front to back and read back to front. A 1 bit is written after the last one so the decoder can
+// find where the bits end in the last byte.
+
+const MaxTANSTableLog = 14
+
+type tansDecodeEntry struct {
+	sym     byte
+	numBits uint8
+	base    uint32 // next state is base + the next numBits bits
+}
+
+type tansTables struct {
+	decode     []tansDecodeEntry
+	nextState  []uint32 // M + state, for every (symbol, occurrence) at cumFreq[sym] + occurrence
+	cumFreq    [numByteSymbols]uint32
+	symNumBits [numByteSymbols]int // bit length of the frequency, saves a bits.Len in the encoder
+}
+
+// spreadStep visits every slot of the table exactly once since it is odd and the table size is a power of two
+func spreadStep(tableSize uint32) uint32 {
+	return tableSize>>1 + tableSize>>3 + 3
+}
+
+func newTansTables(normFreq *[numByteSymbols]uint32, tableLog uint) *tansTables {
+	tableSize := uint32(1) << tableLog
+	t := &tansTables{
+		decode:    make([]tansDecodeEntry, tableSize),
+		nextState: make([]uint32, tableSize),
+	}
+	for sym := 1; sym < numByteSymbols; sym++ {
+		t.cumFreq[sym] = t.cumFreq[sym-1] + normFreq[sym-1]
+	}
+	for sym := range t.symNumBits {
+		t.symNumBits[sym] = bits.Len32(normFreq[sym])
+	}
+
+	slotSyms := make([]byte, tableSize)
+	pos, step, mask := uint32(0), spreadStep(tableSize), tableSize-1
+	for sym := 0; sym < numByteSymbols; sym++ {
+		for i := uint32(0); i < normFreq[sym]; i++ {
+			slotSyms[pos] = byte(sym)
+			pos = (pos + step) & mask
+		}
+	}
+
+	// the k-th state of a symbol (in table order) decodes to x = freq + k, which goes back up to a full state by
+	// shifting in enough bits to get to [M, 2M)
+	var occurrences [numByteSymbols]uint32
+	for state := uint32(0); state < tableSize; state++ {
+		sym := slotSyms[state]
+		x := normFreq[sym] + occurrences[sym]
+		numBits := tableLog - uint(bits.Len32(x)-1)
+		t.decode[state] = tansDecodeEntry{sym: sym, numBits: uint8(numBits), base: x<<numBits - tableSize}
+		t.nextState[t.cumFreq[sym]+occurrences[sym]] = tableSize + state
+		occurrences[sym]++
+	}
+	return t
+}
+
+// CompressTANS codes srcData with numStates interleaved tANS states sharing one bit stream
+func CompressTANS(srcData *[]byte) ([]byte, bool) {
+	if len(*srcData) == 0 {
+		return serializeHeader(0, nil, 0), false
+	}
+	normFreq, tableLog := getNormalizedFrequencies(srcData, MaxTANSTableLog)
+	t := newTansTables(&normFreq, tableLog)
+	tableSize := uint32(1) << tableLog
+
+	var states [numStates]uint32
+	for i := range states {
+		states[i] = tableSize
+	}
+	bw := bitWriter{buf: serializeHeader(len(*srcData), &normFreq, tableLog)}
+	for i := len(*srcData) - 1; i >= 0; i-- {
+		sym := (*srcData)[i]
+		x := states[i%numStates]
+		freq := normFreq[sym]
+		// x >> numBits has to end up in [freq, 2*freq)
+		numBits := uint(bits.Len32(x) - t.symNumBits[sym])
+		if x>>numBits < freq {
+			numBits--
+		}
+		bw.writeBits(x, numBits)
+		states[i%numStates] = t.nextState[t.cumFreq[sym]+x>>numBits-freq]
+	}
+	for i := numStates - 1; i >= 0; i-- {
+		bw.writeBits(states[i]-tableSize, tableLog)
+	}
+	bw.writeBits(1, 1)
+
+	compressedData := bw.bytes()
+	return compressedData, len(compressedData) < len(*srcData)
+}
+
+func DecompressTANS(data *[]byte) (*[]byte, error) {
+	return DecompressTANSWithLimit(data, DefaultMaxDecompressedLen)
+}
+
+// DecompressTANSWithLimit fails with ErrCorruptData instead of decoding more than maxLen bytes
+func DecompressTANSWithLimit(data *[]byte, maxLen uint64) (*[]byte, error) {
+	numSymbols, normFreq, tableLog, headerLen, err := deserializeHeader(*data, MaxTANSTableLog)
+	if err != nil {
+		return nil, err
+	}
+	uncompressedData := make([]byte, 0)
+	if numSymbols == 0 {
+		return &uncompressedData, nil
+	}
+	br, err := newBackwardBitReader((*data)[headerLen:])
+	if err != nil {
+		return nil, err
+	}
+	if err = checkNumSymbols(numSymbols, &normFreq, tableLog, len(*data)-headerLen, maxLen); err != nil {
+		return nil, err
+	}
+	t := newTansTables(&normFreq, tableLog)
+
+	var states [numStates]uint32
+	for i := range states {
+		states[i] = br.readBits(tableLog)
+	}
+	uncompressedData = make([]byte, numSymbols)
+	for i := range uncompressedData {
+		entry := t.decode[states[i%numStates]]
+		uncompressedData[i] = entry.sym
+		states[i%numStates] = entry.base + br.readBits(uint(entry.numBits))
+	}
+
+	if br.overrun {
+		return nil, fmt.Errorf("%w: coded data ends before the last symbol", ErrCorruptData)
+	}
+	for i, state := range states {
+		if state != 0 {
+			return nil, fmt.Errorf("%w: coder state %v did not end where it started", ErrCorruptData, i)
+		}
+	}
+	if br.pos != 0 {
+		return nil, fmt.Errorf("%w: %v bits left over after the last symbol", ErrCorruptData, br.pos)
+	}
+	return &uncompressedData, nil
+}
+
+// bitWriter packs bits into bytes lowest bit first
+type bitWriter struct {
+	buf     []byte
+	acc     uint64
+	numBits uint
+}
+
+func (w *bitWriter) writeBits(value uint32, numBits uint) {
+	w.acc |= uint64(value&(1<<numBits-1)) << w.numBits
+	w.numBits += numBits
+	for w.numBits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.numBits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.numBits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.numBits = 0, 0
+	}
+	return w.buf
+}
+
+// backwardBitReader reads the bits a bitWriter wrote starting from the last one
+type backwardBitReader struct {
+	data    []byte
+	pos     int // number of bits that haven't been read yet
+	overrun bool
+}
+
+func newBackwardBitReader(data []byte) (*backwardBitReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, fmt.Errorf("%w: missing end of bits marker", ErrCorruptData)
+	}
+	// everything above the marker bit is padding, and the marker itself isn't data either
+	pos := 8*len(data) - bits.LeadingZeros8(data[len(data)-1]) - 1
+	return &backwardBitReader{data: data, pos: pos}, nil
+}
+
+// readBits returns the numBits bits right before pos. Reading past the start gives 0s and sets overrun
+func (r *backwardBitReader) readBits(numBits uint) uint32 {
+	r.pos -= int(numBits)
+	if r.pos < 0 {
+		r.overrun = true
+		r.pos = 0
+		return 0
+	}
+	value := uint64(0)
+	firstByte := r.pos >> 3
+	if firstByte+8 <= len(r.data) {
+		value = binary.LittleEndian.Uint64(r.data[firstByte:])
+	} else {
+		for i := len(r.data) - 1; i >= firstByte; i-- {
+			value = value<<8 | uint64(r.data[i])
+		}
+	}
+	return uint32(value>>(r.pos&7)) & (1<<numBits - 1)
+}
@@ -0,0 +1,84 @@
+package arithmeticcoding
+
+import "github.com/ElwinCabrera/go-compression/compressionutils"
+
+// The adaptive model starts with every symbol (and the end symbol) having a count of 1 and bumps the count of each
+// symbol after it is coded. The decoder makes the exact same updates after it decodes a symbol, so both sides always
+// have the same counts and there is no frequency table to send, the output is just the coded bits.
+//
+// The total can't go over what the range coder allows (MaxRangeTotal). When it would, all counts are halved (never
+// below 1). That also makes recent symbols count more than old ones, so the model
+// follows data that changes over time.
+
+const (
+	numAdaptiveSymbols = 257 // every byte plus ENDSYMBOL
+	adaptiveIncrement  = 32
+	adaptiveMaxTotal   = MaxRangeTotal
+)
+
+// AdaptiveModel is the order-0 model CompressAdaptive uses. NewAdaptiveModel has to be used to create one. The counts
+// are kept in a Fenwick tree so finding a symbol's range or the symbol for a value is O(log n) instead of adding up
+// every count before it
+type AdaptiveModel struct {
+	freq  *compressionutils.FenwickTree
+	total uint
+}
+
+func NewAdaptiveModel() *AdaptiveModel {
+	counts := make([]uint64, numAdaptiveSymbols)
+	for i := range counts {
+		counts[i] = 1
+	}
+	return &AdaptiveModel{freq: compressionutils.NewFenwickTreeFromCounts(counts), total: numAdaptiveSymbols}
+}
+
+func (m *AdaptiveModel) Total() uint {
+	return m.total
+}
+
+func (m *AdaptiveModel) Interval(sym uint16) (uint, uint) {
+	if sym >= numAdaptiveSymbols {
+		return 0, 0
+	}
+	start := uint(m.freq.PrefixSum(int(sym)))
+	return start, start + uint(m.freq.Get(int(sym)))
+}
+
+func (m *AdaptiveModel) SymbolAt(value uint) (uint16, uint, uint) {
+	if value >= m.total {
+		return 0, 0, 0
+	}
+	sym := uint16(m.freq.Find(uint64(value)))
+	start, end := m.Interval(sym)
+	return sym, start, end
+}
+
+func (m *AdaptiveModel) Update(sym uint16) {
+	if m.total+adaptiveIncrement > adaptiveMaxTotal {
+		m.rescale()
+	}
+	m.freq.Add(int(sym), adaptiveIncrement)
+	m.total += adaptiveIncrement
+}
+
+func (m *AdaptiveModel) rescale() {
+	counts := make([]uint64, numAdaptiveSymbols)
+	m.total = 0
+	for i := range counts {
+		counts[i] = (m.freq.Get(i) + 1) / 2
+		m.total += uint(counts[i])
+	}
+	m.freq = compressionutils.NewFenwickTreeFromCounts(counts)
+}
+
+// CompressAdaptive arithmetic codes srcData with the adaptive model, so unlike Compress there is no frequency table in
+// the output
+func CompressAdaptive(srcData *[]byte) ([]byte, bool) {
+	compressedData, _ := EncodeWithModel(srcData, NewAdaptiveModel())
+	return compressedData, len(compressedData) < len(*srcData)
+}
+
+// DecompressAdaptive returns false if the end symbol doesn't show up before the data runs out
+func DecompressAdaptive(compressedData *[]byte) ([]byte, bool) {
+	return DecodeWithModel(compressedData, NewAdaptiveModel())
+}
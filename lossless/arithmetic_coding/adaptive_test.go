@@ -0,0 +1,76 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"testing"
+
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+func getAdaptiveTestData() [][]byte {
+	// the last one changes its alphabet half way through so the model has to rescale and move on
+	changing := append(testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(50000, 4),
+		bytes.Repeat([]byte("xyz"), 20000)...)
+	return [][]byte{
+		{},
+		{'A'},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 1),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 2),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 26),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(50000, 256),
+		changing,
+	}
+}
+
+func TestAdaptiveCompressAndDecompress(t *testing.T) {
+	for i, data := range getAdaptiveTestData() {
+		compressedData, _ := CompressAdaptive(&data)
+		decompressedData, ok := DecompressAdaptive(&compressedData)
+		if !ok {
+			t.Fatalf("DecompressAdaptive did not find the end of dataset #%v", i)
+		}
+		if !bytes.Equal(data, decompressedData) {
+			t.Fatalf("Decompressed data does not match original data for dataset #%v", i)
+		}
+	}
+}
+
+func TestAdaptiveModelRescales(t *testing.T) {
+	m := NewAdaptiveModel()
+	for i := 0; i < 100000; i++ {
+		m.Update(uint16(i % 3))
+		if m.total > adaptiveMaxTotal {
+			t.Fatalf("total went over %v after %v updates", adaptiveMaxTotal, i+1)
+		}
+	}
+	sum := uint(0)
+	for sym := 0; sym < numAdaptiveSymbols; sym++ {
+		freq := uint(m.freq.Get(sym))
+		if freq == 0 {
+			t.Fatalf("symbol %v ended up with a zero count", sym)
+		}
+		sum += freq
+	}
+	if sum != m.total {
+		t.Fatalf("counts add up to %v but the total is %v", sum, m.total)
+	}
+}
+
+func TestAdaptiveHasNoTable(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	adaptive, _ := CompressAdaptive(&data)
+	static, _ := Compress(&data)
+	if len(adaptive) >= len(static) {
+		t.Fatalf("adaptive output (%v bytes) is not smaller than the output with a frequency table (%v bytes)", len(adaptive), len(static))
+	}
+}
+
+func TestAdaptiveCorruptData(t *testing.T) {
+	// the end symbol is at the top of the interval so all zeros never decode to it, the decoder has to give up once it
+	// runs out of data
+	corrupt := bytes.Repeat([]byte{0x00}, 64)
+	if _, ok := DecompressAdaptive(&corrupt); ok {
+		t.Fatalf("expected DecompressAdaptive to fail on data without an end symbol")
+	}
+}
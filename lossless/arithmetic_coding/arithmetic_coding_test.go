@@ -14,8 +14,10 @@ func testSerializationAndDeserialization(t *testing.T, testingData *[]byte) {
 
 	serializedFreqTable := serializeFrequencyTable(freqMap)
 
-	deSerializedFreqTable, serializedLen := deserializeFrequencyTable(&serializedFreqTable)
-
+	deSerializedFreqTable, numSymbols, serializedLen, ok := deserializeFrequencyTable(serializedFreqTable)
+	if !ok || numSymbols != uint64(len(*testingData)) {
+		t.Fatalf("Could not deserialize the frequency table or got the wrong number of symbols. Got %v symbols but expected %v\n", numSymbols, len(*testingData))
+	}
 	if serializedLen != len(serializedFreqTable) {
 		t.Fatalf("When Deserializing the expected total original serialized length does not equal to the original serialized lenth. Got length %v but expected %v. Expected table: %v ... but Got table: %v\n", serializedLen, len(serializedFreqTable), freqMap, deSerializedFreqTable)
 
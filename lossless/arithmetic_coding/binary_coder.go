@@ -0,0 +1,133 @@
+package arithmeticcoding
+
+// The binary coder is the same range coder but every decision is a single bit with its own adaptive probability, the
+// way LZMA and CABAC do it. There is no alphabet and no end symbol, the caller decides what the bits mean and when to
+// stop, so a compressor built on top of it has to code the length (or its own end marker) itself.
+//
+// A probability is the chance of the bit being 0 scaled to BitProbBits (11) bits. Splitting the range is then just a
+// multiply, bound = (range >> 11) * prob, and the 0 gets [low, low + bound) while the 1 gets the rest. After every bit
+// the probability moves 1/32 of the way towards the bit that was coded (the shift update), so it follows the data
+// without any counts or divisions. The 11 bits plus the 5 bit shift keep the probability between 31/2048 and 2017/2048,
+// which means a bit never costs more than about 6 bits even if it was predicted badly.
+
+const (
+	BitProbBits = 11
+	// BitProbInit is 0.5, what every probability has to start out as on both sides
+	BitProbInit     = 1 << (BitProbBits - 1)
+	bitProbTotal    = 1 << BitProbBits
+	bitProbMoveBits = 5
+	maxDirectBits   = 32
+)
+
+// BitProb is the adaptive probability of a bit being 0. Use BitProbInit or NewBitProbs to start one
+type BitProb uint16
+
+// NewBitProbs returns n probabilities that are all set to BitProbInit
+func NewBitProbs(n int) []BitProb {
+	probs := make([]BitProb, n)
+	for i := range probs {
+		probs[i] = BitProbInit
+	}
+	return probs
+}
+
+// BinaryEncoder codes bits one at a time into a byte slice. Finish has to be called after the last bit
+type BinaryEncoder struct {
+	enc *rangeEncoder
+}
+
+func NewBinaryEncoder() *BinaryEncoder {
+	return &BinaryEncoder{enc: newRangeEncoder()}
+}
+
+// EncodeBit codes bit (0 or 1, anything else counts as 1) with prob and then updates prob
+func (e *BinaryEncoder) EncodeBit(prob *BitProb, bit uint) {
+	bound := (e.enc.rng >> BitProbBits) * uint64(*prob)
+	if bit == 0 {
+		e.enc.rng = bound
+		*prob += (bitProbTotal - *prob) >> bitProbMoveBits
+	} else {
+		e.enc.low += bound
+		e.enc.rng -= bound
+		*prob -= *prob >> bitProbMoveBits
+	}
+	e.normalize()
+}
+
+// EncodeDirectBits codes the lowest numBits (at most 32) of value, top bit first, each with a fixed probability of 0.5.
+// Good for bits that are close to random anyway like the low bits of a match distance
+func (e *BinaryEncoder) EncodeDirectBits(value uint32, numBits int) {
+	for i := min(numBits, maxDirectBits) - 1; i >= 0; i-- {
+		e.enc.rng >>= 1
+		if (value>>i)&1 == 1 {
+			e.enc.low += e.enc.rng
+		}
+		e.normalize()
+	}
+}
+
+// Finish flushes what is left of the interval and returns the coded data
+func (e *BinaryEncoder) Finish() []byte {
+	return e.enc.finish()
+}
+
+func (e *BinaryEncoder) normalize() {
+	for e.enc.rng < rangeTop {
+		e.enc.rng <<= 8
+		e.enc.shiftLow()
+	}
+}
+
+// BinaryDecoder reads back the bits a BinaryEncoder wrote. It has to be given the same probabilities in the same order
+// the encoder used them
+type BinaryDecoder struct {
+	dec *rangeDecoder
+}
+
+func NewBinaryDecoder(data []byte) *BinaryDecoder {
+	return &BinaryDecoder{dec: newRangeDecoder(data)}
+}
+
+func (d *BinaryDecoder) DecodeBit(prob *BitProb) uint {
+	bound := (d.dec.rng >> BitProbBits) * uint64(*prob)
+	bit := uint(0)
+	if d.dec.code < bound {
+		d.dec.rng = bound
+		*prob += (bitProbTotal - *prob) >> bitProbMoveBits
+	} else {
+		d.dec.code -= bound
+		d.dec.rng -= bound
+		*prob -= *prob >> bitProbMoveBits
+		bit = 1
+	}
+	d.normalize()
+	return bit
+}
+
+func (d *BinaryDecoder) DecodeDirectBits(numBits int) uint32 {
+	value := uint32(0)
+	for i := 0; i < min(numBits, maxDirectBits); i++ {
+		d.dec.rng >>= 1
+		bit := uint32(0)
+		if d.dec.code >= d.dec.rng {
+			d.dec.code -= d.dec.rng
+			bit = 1
+		}
+		value = (value << 1) | bit
+		d.normalize()
+	}
+	return value
+}
+
+// Overrun reports whether the decoder had to read further past the end of the data than valid data ever needs, which
+// means the data is corrupt or more bits were decoded than were encoded. The bits decoded after that are garbage
+func (d *BinaryDecoder) Overrun() bool {
+	return d.dec.bytesPastEnd > maxBytesPastEnd
+}
+
+func (d *BinaryDecoder) normalize() {
+	for d.dec.rng < rangeTop {
+		d.dec.rng <<= 8
+		d.dec.code = ((d.dec.code << 8) | uint64(d.dec.nextByte())) & rangeInitial
+	}
+}
@@ -0,0 +1,179 @@
+package arithmeticcoding
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBinaryCoderSkewedBits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const numBits = 200000
+	const probOne = 0.05
+	bitsToCode := make([]uint, numBits)
+	for i := range bitsToCode {
+		if rnd.Float64() < probOne {
+			bitsToCode[i] = 1
+		}
+	}
+
+	enc := NewBinaryEncoder()
+	prob := BitProb(BitProbInit)
+	carries := 0
+	for _, bit := range bitsToCode {
+		enc.EncodeBit(&prob, bit)
+		if enc.enc.low>>32 != 0 {
+			carries++
+		}
+	}
+	encoded := enc.Finish()
+	if carries == 0 {
+		t.Fatalf("no carries happened, the test data needs to be changed to cover them")
+	}
+
+	// the shift update never settles exactly on the real probability, so allow a few percent over the entropy
+	entropy := -probOne*math.Log2(probOne) - (1-probOne)*math.Log2(1-probOne)
+	if maxBytes := int(1.05 * entropy * numBits / 8); len(encoded) > maxBytes {
+		t.Errorf("coded %v bits into %v bytes, expected at most %v", numBits, len(encoded), maxBytes)
+	}
+
+	dec := NewBinaryDecoder(encoded)
+	prob = BitProbInit
+	for i, bit := range bitsToCode {
+		if decodedBit := dec.DecodeBit(&prob); decodedBit != bit {
+			t.Fatalf("bit #%v decoded to %v but expected %v", i, decodedBit, bit)
+		}
+	}
+	if dec.Overrun() {
+		t.Fatalf("decoder read too far past the end of valid data")
+	}
+}
+
+func TestBinaryCoderDirectBits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	values := make([]uint32, 1000)
+	for i := range values {
+		values[i] = rnd.Uint32()
+	}
+	probs := NewBitProbs(1)
+
+	enc := NewBinaryEncoder()
+	for i, value := range values {
+		enc.EncodeDirectBits(value, i%33)
+		enc.EncodeBit(&probs[0], uint(value&1))
+	}
+	encoded := enc.Finish()
+
+	dec := NewBinaryDecoder(encoded)
+	probs = NewBitProbs(1)
+	for i, value := range values {
+		numBits := i % 33
+		if decoded := dec.DecodeDirectBits(numBits); decoded != uint32(uint64(value)&(1<<numBits-1)) {
+			t.Fatalf("value #%v decoded to %x from %v bits of %x", i, decoded, numBits, value)
+		}
+		if bit := dec.DecodeBit(&probs[0]); bit != uint(value&1) {
+			t.Fatalf("bit after value #%v decoded to %v", i, bit)
+		}
+	}
+}
+
+// an order-1 byte model is the kind of thing the bit trees are for, one 8 bit tree per previous byte
+func TestBitTreeOrder1Bytes(t *testing.T) {
+	data := getLogTestData(2000)
+
+	enc := NewBinaryEncoder()
+	trees := make([]*BitTree, 256)
+	for i := range trees {
+		trees[i] = NewBitTree(8)
+	}
+	prev := byte(0)
+	for _, bt := range data {
+		trees[prev].Encode(enc, uint32(bt))
+		prev = bt
+	}
+	encoded := enc.Finish()
+
+	order0, _ := CompressAdaptive(&data)
+	if len(encoded) >= len(order0) {
+		t.Errorf("order-1 bit trees took %v bytes, that should be less than the %v bytes of order-0 coding", len(encoded), len(order0))
+	}
+
+	dec := NewBinaryDecoder(encoded)
+	for i := range trees {
+		trees[i] = NewBitTree(8)
+	}
+	prev = 0
+	for i, bt := range data {
+		decoded := byte(trees[prev].Decode(dec))
+		if decoded != bt {
+			t.Fatalf("byte #%v decoded to %v but expected %v", i, decoded, bt)
+		}
+		prev = decoded
+	}
+}
+
+func TestBitTreeReverse(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	values := make([]uint32, 5000)
+	for i := range values {
+		values[i] = uint32(rnd.Intn(16))
+	}
+
+	enc := NewBinaryEncoder()
+	tree := NewBitTree(4)
+	for _, value := range values {
+		tree.EncodeReverse(enc, value)
+	}
+	encoded := enc.Finish()
+
+	dec := NewBinaryDecoder(encoded)
+	tree = NewBitTree(4)
+	for i, value := range values {
+		if decoded := tree.DecodeReverse(dec); decoded != value {
+			t.Fatalf("value #%v decoded to %v but expected %v", i, decoded, value)
+		}
+	}
+}
+
+func TestIntCoder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	values := []uint32{0, 1, 2, 3, 15, 16, 17, 31, 32, 33, 1 << 20, math.MaxUint32, math.MaxUint32 - 1}
+	for i := 0; i < 20000; i++ {
+		// mostly small numbers like match lengths, every so often a big one
+		values = append(values, uint32(rnd.ExpFloat64()*20), rnd.Uint32()>>rnd.Intn(32))
+	}
+
+	enc := NewBinaryEncoder()
+	coder := NewIntCoder()
+	for _, value := range values {
+		coder.Encode(enc, value)
+	}
+	encoded := enc.Finish()
+
+	dec := NewBinaryDecoder(encoded)
+	coder = NewIntCoder()
+	for i, value := range values {
+		if decoded := coder.Decode(dec); decoded != value {
+			t.Fatalf("value #%v decoded to %v but expected %v", i, decoded, value)
+		}
+	}
+	if dec.Overrun() {
+		t.Fatalf("decoder read too far past the end of valid data")
+	}
+}
+
+func TestBinaryDecoderOverrun(t *testing.T) {
+	enc := NewBinaryEncoder()
+	tree := NewBitTree(8)
+	tree.Encode(enc, 'A')
+	encoded := enc.Finish()
+
+	dec := NewBinaryDecoder(encoded)
+	tree = NewBitTree(8)
+	for i := 0; i < 100 && !dec.Overrun(); i++ {
+		tree.Decode(dec)
+	}
+	if !dec.Overrun() {
+		t.Fatalf("expected the decoder to notice it went past the end of the data")
+	}
+}
@@ -0,0 +1,109 @@
+package arithmeticcoding
+
+import "math/bits"
+
+// A bit tree codes a numBits wide symbol as numBits binary decisions, top bit first. Every bit gets its own probability
+// picked by the bits that came before it (the path down the tree), so a byte coded with an 8 bit tree has the same
+// model as a 256 symbol adaptive model would, but only needs BinaryEncoder underneath. The probabilities are stored
+// heap style, node i has its children at 2i and 2i+1 and the root is 1.
+
+type BitTree struct {
+	numBits int
+	probs   []BitProb
+}
+
+// NewBitTree returns a tree for symbols of numBits bits. NewBitTree(8) codes bytes
+func NewBitTree(numBits int) *BitTree {
+	return &BitTree{numBits: numBits, probs: NewBitProbs(1 << numBits)}
+}
+
+func (t *BitTree) NumBits() int {
+	return t.numBits
+}
+
+// Encode codes the lowest NumBits() bits of sym
+func (t *BitTree) Encode(e *BinaryEncoder, sym uint32) {
+	node := uint32(1)
+	for i := t.numBits - 1; i >= 0; i-- {
+		bit := (sym >> i) & 1
+		e.EncodeBit(&t.probs[node], uint(bit))
+		node = (node << 1) | bit
+	}
+}
+
+func (t *BitTree) Decode(d *BinaryDecoder) uint32 {
+	node := uint32(1)
+	for i := 0; i < t.numBits; i++ {
+		node = (node << 1) | uint32(d.DecodeBit(&t.probs[node]))
+	}
+	return node - (1 << t.numBits)
+}
+
+// EncodeReverse codes the bits of sym starting from the lowest one. The low bits of numbers like match distances depend
+// on each other more than on the high bits so they model better this way
+func (t *BitTree) EncodeReverse(e *BinaryEncoder, sym uint32) {
+	node := uint32(1)
+	for i := 0; i < t.numBits; i++ {
+		bit := sym & 1
+		sym >>= 1
+		e.EncodeBit(&t.probs[node], uint(bit))
+		node = (node << 1) | bit
+	}
+}
+
+func (t *BitTree) DecodeReverse(d *BinaryDecoder) uint32 {
+	node, sym := uint32(1), uint32(0)
+	for i := 0; i < t.numBits; i++ {
+		bit := uint32(d.DecodeBit(&t.probs[node]))
+		node = (node << 1) | bit
+		sym |= bit << i
+	}
+	return sym
+}
+
+// IntCoder codes any uint32 in two parts. First the number of bits in it (0 to 32) with a bit tree, then the bits
+// below the leading 1. The top intModelBits of those get their own bit tree for every bit count since they are usually
+// skewed (small lengths are more likely than big ones), anything lower is close enough to random to go out as direct
+// bits. Small numbers end up cheap and no number costs more than a few bits over its length
+type IntCoder struct {
+	lenTree    *BitTree
+	valueTrees [33]*BitTree
+}
+
+const intModelBits = 4
+
+func NewIntCoder() *IntCoder {
+	c := &IntCoder{lenTree: NewBitTree(6)}
+	for numBits := 2; numBits < len(c.valueTrees); numBits++ {
+		c.valueTrees[numBits] = NewBitTree(min(numBits-1, intModelBits))
+	}
+	return c
+}
+
+func (c *IntCoder) Encode(e *BinaryEncoder, value uint32) {
+	numBits := bits.Len32(value)
+	c.lenTree.Encode(e, uint32(numBits))
+	if numBits < 2 {
+		// 0 and 1 are fully described by their length
+		return
+	}
+	tree := c.valueTrees[numBits]
+	numDirectBits := numBits - 1 - tree.NumBits()
+	tree.Encode(e, value>>numDirectBits)
+	e.EncodeDirectBits(value, numDirectBits)
+}
+
+func (c *IntCoder) Decode(d *BinaryDecoder) uint32 {
+	numBits := int(c.lenTree.Decode(d))
+	if numBits < 2 {
+		return uint32(numBits)
+	}
+	if numBits > 32 {
+		// only corrupt data gets here
+		numBits = 32
+	}
+	tree := c.valueTrees[numBits]
+	numDirectBits := numBits - 1 - tree.NumBits()
+	value := (1 << tree.NumBits()) | tree.Decode(d)
+	return value<<numDirectBits | d.DecodeDirectBits(numDirectBits)
+}
@@ -3,138 +3,165 @@ package arithmeticcoding
 //This is the finite version because we are using the infinite version of the algorithm
 //and since our computer only has a finite number of bits to represent probabilities aka floats
 //this can only compress a finite number of characters (~32 characters if using 64 bit floating point number)
+//
+//To get around that the data is coded in chunks. A chunk keeps narrowing the interval until it is narrower than
+//finiteMinWidth, then the shortest binary fraction inside the interval is written out and the next chunk starts over
+//from [0, 1). The decoder can tell where a chunk ends on its own since it narrows the interval the exact same way.
+//
+//The probabilities are quantized to multiples of 1/2^finiteProbBits before they are used. That way they are exact in
+//a float and the header only needs small integers, and both sides get bit for bit the same intervals out of the same
+//float math. Every new interval is also clamped to the one it came from so rounding can never push a symbol's interval
+//outside of its parent.
+//
+//Compressed data layout:
+//	<num_symbols><num_used-1><symbol><quantized prob> ... <chunks>
+//	  uvarint      1 byte    1 byte      uvarint
+//where every chunk is a 6 bit length n followed by the n bits of the fraction (most significant first)
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"github.com/ElwinCabrera/go-compression/compressionutils"
 	bitstructs "github.com/ElwinCabrera/go-data-structs/bit-structs"
 	"math"
+	"sort"
 )
 
+const (
+	finiteProbBits     = 12
+	finiteProbTotal    = 1 << finiteProbBits
+	finiteCodeLenBits  = 6
+	finiteMinWidthBits = 32
+	// finiteMaxDecompressedLen is as many symbols as finiteDecompress will decode. A symbol that has all of the
+	// probability never narrows the interval, so however many of it there are they code to the same single chunk and
+	// the count can't be checked against the data
+	finiteMaxDecompressedLen = 1 << 30
+)
+
+// with the interval at least 2^-32 wide and every probability at least 2^-12 a chunk never needs more than ~46 bits,
+// well below the 53 bits of a float64
+var finiteMinWidth = math.Ldexp(1, -finiteMinWidthBits)
+
 type probInterval struct {
 	start, end, width float64
 }
 
+// finiteModel has the symbols in the order their intervals come in so the decoder can binary search them
+type finiteModel struct {
+	syms      []uint16
+	intervals []probInterval
+}
+
 func finiteCompress(srcData *[]byte) ([]byte, bool) {
 	freqMap := compressionutils.GetSymbolFrequencyMap(srcData)
 	probabilityMap := compressionutils.GetSymbolProbMapFromFreqMap(freqMap, len(*srcData))
-	return finiteEncodeWithProbabilityModel(srcData, probabilityMap)
+	quantizedProbs := quantizeProbabilities(probabilityMap)
+
+	compressedData := serializeQuantizedProbabilities(len(*srcData), quantizedProbs)
+	if len(*srcData) == 0 {
+		return compressedData, false
+	}
+	symbols := make([]uint16, len(*srcData))
+	for i, bt := range *srcData {
+		symbols[i] = uint16(bt)
+	}
+	compressedData = append(compressedData, finiteEncode(symbols, newFiniteModel(quantizedProbs))...)
+	return compressedData, len(compressedData) < len(*srcData)
 }
 
-func finiteDecompress(encodedData *[]byte) []byte {
-	//TODO: Need to find a way to send the probability map
-	probabilityMap := map[uint16]float64{}
-	return finiteDecodeWithProbabilityModel(encodedData, &probabilityMap)
+func finiteDecompress(encodedData *[]byte) ([]byte, bool) {
+	numSymbols, quantizedProbs, headerLen, ok := deserializeQuantizedProbabilities(*encodedData)
+	if !ok {
+		return nil, false
+	}
+	if numSymbols == 0 {
+		return []byte{}, true
+	}
+	// a symbol that doesn't have all of the probability costs more than 2^-finiteProbBits bits. When one symbol does
+	// have all of it there is only the one chunk, see finiteMaxDecompressedLen
+	if numSymbols > finiteMaxDecompressedLen || (len(quantizedProbs) > 1 && numSymbols > uint64(len(*encodedData)-headerLen)*8<<finiteProbBits) {
+		return nil, false
+	}
+	data := (*encodedData)[headerLen:]
+	return finiteDecode(&data, newFiniteModel(quantizedProbs), int(numSymbols))
 }
 
+// finiteEncodeWithProbabilityModel codes srcData followed by ENDSYMBOL, so probabilityMap has to have ENDSYMBOL in it.
+// The probabilities are quantized the same way finiteCompress does it
 func finiteEncodeWithProbabilityModel(srcData *[]byte, probabilityMap *map[uint16]float64) ([]byte, bool) {
 	//_, canCompress := canCompressData(*probabilityMap, float64(len(*srcData)))
 	//if !canCompress {
 	//	return []byte{}, false
 	//}
-	symToCumProb := getCumulativeProbabilitiesFromProbMap(probabilityMap)
-	return finiteEncode(srcData, symToCumProb)
+	quantizedProbs := quantizeProbabilities(probabilityMap)
+	symbols := make([]uint16, 0, len(*srcData)+1)
+	for _, bt := range *srcData {
+		symbols = append(symbols, uint16(bt))
+	}
+	symbols = append(symbols, ENDSYMBOL)
+	for _, sym := range symbols {
+		if quantizedProbs[sym] == 0 {
+			return []byte{}, false
+		}
+	}
+	return finiteEncode(symbols, newFiniteModel(quantizedProbs)), true
 }
 
 func finiteDecodeWithProbabilityModel(encodedBytes *[]byte, probabilityMap *map[uint16]float64) []byte {
-	cm := getCumulativeProbabilitiesFromProbMap(probabilityMap)
-	res, _ := finiteDecode(encodedBytes, cm)
+	res, _ := finiteDecode(encodedBytes, newFiniteModel(quantizeProbabilities(probabilityMap)), -1)
 	return res
 }
 
-func finiteEncode(srcData *[]byte, symToCumulativeProb map[uint16]probInterval) ([]byte, bool) {
-
-	end := 1.0
-	start := 0.0
-
-	for i := 0; i < len(*srcData)+1; i++ {
-		sym := uint16(0)
-		if i >= len(*srcData) {
-			sym = ENDSYMBOL
-		} else {
-			sym = uint16((*srcData)[i])
-		}
-
-		width := end - start
-		end = start + (width * symToCumulativeProb[sym].end)
-		start = start + (width * symToCumulativeProb[sym].start)
-
-		if start == end {
-			fmt.Printf("Reached maximum number of symbols that can be encoded using 64-bit floating point math\n")
-		}
+func finiteEncode(symbols []uint16, model *finiteModel) []byte {
+	symToInterval := make(map[uint16]probInterval)
+	for i, sym := range model.syms {
+		symToInterval[sym] = model.intervals[i]
 	}
-	fmt.Printf("lowbound: %v, upperbound: %v, width: %v\n", start, end, end-start)
-	//encodedData := utils.GetMidPointProtectingAgainstOverflow(start, end)
 
 	bitSeq := bitstructs.NewDynamicBitSequence()
-	for {
-		if start > 0.5 && end > 0.5 {
-			bitSeq.AppendBitEnd(1)
-			start = (start / 0.5) - 1
-			end = (end / 0.5) - 1
-		} else if start < 0.5 && end < 0.5 {
-			bitSeq.AppendBitEnd(0)
-			start = start / 0.5
-			end = end / 0.5
-		} else if start < 0.5 && end > 0.5 {
-			start = start / 0.5
-			end = (end / 0.5) - 1
-		} else {
-			break
-		}
-
-	}
-	setBit := true
-	if start > 0.5 {
-		bitSeq.AppendBitEnd(1)
-	} else {
-		bitSeq.AppendBitEnd(0)
-		setBit = false
-	}
-	fmt.Printf("Binary representation: 0.%v\n", bitSeq)
-
-	unusedBitsInByte := 0
-	if bitSeq.GetNumBits()%bitstructs.BYTE_LENGTH != 0 {
-		unusedBitsInByte = bitstructs.BYTE_LENGTH - (bitSeq.GetNumBits() % bitstructs.BYTE_LENGTH)
-		bitSeq.ExpandNumOfBitsToUseRemainingBitsInLastByte()
-		for unusedBitsInByte > 0 {
-			bitSeq.SetBit(bitSeq.GetNumBits()-unusedBitsInByte, setBit)
-			unusedBitsInByte--
+	end := 1.0
+	start := 0.0
+	for i, sym := range symbols {
+		start, end = narrowInterval(start, end, symToInterval[sym])
+		if end-start < finiteMinWidth || i == len(symbols)-1 {
+			writeShortestFraction(&bitSeq, start, end)
+			start, end = 0.0, 1.0
 		}
 	}
-
-	fmt.Printf("Binary representation (after adding padding): 0.%v\n", bitSeq)
-
-	return bitSeq.GetBitSeq(), true
+	return bitSeq.GetBitSeq()
 }
 
-func finiteDecode(encodedBytes *[]byte, symToCumulativeProb map[uint16]probInterval) ([]byte, bool) {
-
-	encodedDataAsNum := 0.0
+// finiteDecode decodes numSymbols symbols, or up to ENDSYMBOL when numSymbols is negative
+func finiteDecode(encodedBytes *[]byte, model *finiteModel, numSymbols int) ([]byte, bool) {
 	bitLen := len(*encodedBytes) * bitstructs.BYTE_LENGTH
 	bitSeq := bitstructs.NewBitSequenceFromByteArray(encodedBytes, bitLen)
-	for i := 0; i < bitSeq.GetNumBits(); i++ {
-		bit := bitstructs.BoolToInt(bitSeq.GetBit(i))
-		encodedDataAsNum += float64(bit) * math.Pow(2.0, float64(i+1)*-1.0)
-	}
+	bitIdx := 0
 
 	var buffer bytes.Buffer
 	end := 1.0
 	start := 0.0
-	for {
-		width := end - start
+	encodedDataAsNum, newChunk, ok := 0.0, true, false
+	for i := 0; numSymbols < 0 || i < numSymbols; i++ {
+		if newChunk {
+			if encodedDataAsNum, bitIdx, ok = readFraction(&bitSeq, bitIdx); !ok {
+				return buffer.Bytes(), false
+			}
+			newChunk = false
+		}
 
-		//encodedDataAsNum = (encodedDataAsNum - inter.lowerBound) / inter.width
-		scaledData := (encodedDataAsNum - start) / width
-		sym, inter := getSymbolWithinRange(scaledData, symToCumulativeProb)
-		if sym == ENDSYMBOL {
+		sym, inter, ok := model.symbolWithin(start, end, encodedDataAsNum)
+		if !ok {
+			return buffer.Bytes(), false
+		}
+		if sym == ENDSYMBOL && numSymbols < 0 {
 			break
 		}
 		buffer.WriteByte(byte(sym))
-		end = start + (width * inter.end)
-		start = start + (width * inter.start)
-
+		start, end = narrowInterval(start, end, inter)
+		if end-start < finiteMinWidth {
+			start, end, newChunk = 0.0, 1.0, true
+		}
 	}
 
 	return buffer.Bytes(), true
@@ -158,13 +185,167 @@ func getCumulativeProbabilitiesFromProbMap(probabilityMap *map[uint16]float64) m
 	return symToCumulativeProb
 }
 
-func getSymbolWithinRange(num float64, symToCumulativeProb map[uint16]probInterval) (uint16, probInterval) {
-	for sym, inter := range symToCumulativeProb {
-		if num >= inter.start && num < inter.end {
-			return sym, inter
+func newFiniteModel(quantizedProbs map[uint16]uint64) *finiteModel {
+	probabilityMap := make(map[uint16]float64)
+	for sym, prob := range quantizedProbs {
+		probabilityMap[sym] = float64(prob) / finiteProbTotal
+	}
+	// the quantized probabilities are all multiples of 2^-12 so adding them up here is exact
+	symToCumulativeProb := getCumulativeProbabilitiesFromProbMap(&probabilityMap)
+	model := &finiteModel{}
+	for _, key := range compressionutils.GetArrayOfSortedMapKeys(&probabilityMap) {
+		model.syms = append(model.syms, uint16(key))
+		model.intervals = append(model.intervals, symToCumulativeProb[uint16(key)])
+	}
+	return model
+}
+
+// narrowInterval is the one place the interval math happens so the encoder and decoder always do the same float
+// operations. The float64 conversions keep the compiler from fusing the multiply and add, which rounds differently on
+// some cpus
+func narrowInterval(start, end float64, inter probInterval) (float64, float64) {
+	width := end - start
+	newStart := start + float64(width*inter.start)
+	newEnd := min(start+float64(width*inter.end), end)
+	return newStart, newEnd
+}
+
+// symbolWithin returns the symbol whose part of [start, end) has num in it
+func (m *finiteModel) symbolWithin(start, end, num float64) (uint16, probInterval, bool) {
+	i := sort.Search(len(m.intervals), func(i int) bool {
+		_, symEnd := narrowInterval(start, end, m.intervals[i])
+		return num < symEnd
+	})
+	if i == len(m.intervals) {
+		return 0, probInterval{}, false
+	}
+	if symStart, _ := narrowInterval(start, end, m.intervals[i]); num < symStart {
+		return 0, probInterval{}, false
+	}
+	return m.syms[i], m.intervals[i], true
+}
+
+// writeShortestFraction writes the binary fraction with the fewest bits that is in [start, end)
+func writeShortestFraction(bitSeq *bitstructs.BitSequence, start, end float64) {
+	numBits := 1
+	for ; numBits < 1<<finiteCodeLenBits-1; numBits++ {
+		if math.Ceil(math.Ldexp(start, numBits)) < math.Ldexp(end, numBits) {
+			break
+		}
+	}
+	fraction := uint64(math.Ceil(math.Ldexp(start, numBits)))
+	for i := finiteCodeLenBits - 1; i >= 0; i-- {
+		bitSeq.AppendBitEnd(byte(numBits>>i) & 1)
+	}
+	for i := numBits - 1; i >= 0; i-- {
+		bitSeq.AppendBitEnd(byte(fraction>>i) & 1)
+	}
+}
+
+func readFraction(bitSeq *bitstructs.BitSequence, bitIdx int) (float64, int, bool) {
+	readBits := func(n int) (uint64, bool) {
+		value := uint64(0)
+		for ; n > 0; n-- {
+			if bitIdx >= bitSeq.GetNumBits() {
+				return 0, false
+			}
+			value = (value << 1) | uint64(bitstructs.BoolToInt(bitSeq.GetBit(bitIdx)))
+			bitIdx++
+		}
+		return value, true
+	}
+	numBits, ok := readBits(finiteCodeLenBits)
+	if !ok || numBits == 0 {
+		return 0, bitIdx, false
+	}
+	fraction, ok := readBits(int(numBits))
+	return math.Ldexp(float64(fraction), -int(numBits)), bitIdx, ok
+}
+
+// quantizeProbabilities rounds every probability to a multiple of 1/2^finiteProbBits, at least 1 of them for anything
+// that isn't 0, and fixes up the sum on the most likely symbol
+func quantizeProbabilities(probabilityMap *map[uint16]float64) map[uint16]uint64 {
+	quantizedProbs := make(map[uint16]uint64)
+	total := uint64(0)
+	mostLikely, mostLikelyProb := -1, uint64(0)
+	for _, key := range compressionutils.GetArrayOfSortedMapKeys(probabilityMap) {
+		prob := (*probabilityMap)[uint16(key)]
+		if prob <= 0 {
+			continue
+		}
+		quantizedProbs[uint16(key)] = max(1, uint64(math.Round(prob*finiteProbTotal)))
+		total += quantizedProbs[uint16(key)]
+		if quantizedProbs[uint16(key)] > mostLikelyProb {
+			mostLikely, mostLikelyProb = key, quantizedProbs[uint16(key)]
+		}
+	}
+	if mostLikely < 0 {
+		return quantizedProbs
+	}
+	// when too many symbols got bumped up to 1 the most likely one might not be able to give back the difference, then
+	// keep taking from whichever is biggest
+	for total > finiteProbTotal {
+		biggest := mostLikely
+		for sym, prob := range quantizedProbs {
+			if prob > quantizedProbs[uint16(biggest)] || (prob == quantizedProbs[uint16(biggest)] && int(sym) < biggest) {
+				biggest = int(sym)
+			}
 		}
+		take := min(total-finiteProbTotal, quantizedProbs[uint16(biggest)]-1)
+		quantizedProbs[uint16(biggest)] -= take
+		total -= take
+	}
+	quantizedProbs[uint16(mostLikely)] += finiteProbTotal - total
+	return quantizedProbs
+}
+
+func serializeQuantizedProbabilities(numSymbols int, quantizedProbs map[uint16]uint64) []byte {
+	serialized := binary.AppendUvarint(nil, uint64(numSymbols))
+	if numSymbols == 0 {
+		return serialized
+	}
+	serialized = append(serialized, byte(len(quantizedProbs)-1))
+	for _, key := range compressionutils.GetArrayOfSortedMapKeys(&quantizedProbs) {
+		serialized = append(serialized, byte(key))
+		serialized = binary.AppendUvarint(serialized, quantizedProbs[uint16(key)])
+	}
+	return serialized
+}
+
+// deserializeQuantizedProbabilities returns the symbol count, the probabilities and the size of the header. The
+// probabilities have to add up to exactly 1
+func deserializeQuantizedProbabilities(data []byte) (uint64, map[uint16]uint64, int, bool) {
+	quantizedProbs := make(map[uint16]uint64)
+	numSymbols, pos := binary.Uvarint(data)
+	if pos <= 0 {
+		return 0, nil, 0, false
+	}
+	if numSymbols == 0 {
+		return 0, quantizedProbs, pos, true
+	}
+	if pos >= len(data) {
+		return 0, nil, 0, false
+	}
+	numUsed := int(data[pos]) + 1
+	pos++
+	total := uint64(0)
+	for i := 0; i < numUsed; i++ {
+		if pos >= len(data) {
+			return 0, nil, 0, false
+		}
+		sym := uint16(data[pos])
+		prob, n := binary.Uvarint(data[pos+1:])
+		if _, seen := quantizedProbs[sym]; n <= 0 || prob == 0 || prob > finiteProbTotal || seen {
+			return 0, nil, 0, false
+		}
+		quantizedProbs[sym] = prob
+		total += prob
+		pos += 1 + n
+	}
+	if total != finiteProbTotal {
+		return 0, nil, 0, false
 	}
-	return 0, probInterval{}
+	return numSymbols, quantizedProbs, pos, true
 }
 
 func canCompressData(probabilityMap map[uint16]float64, srcDataLen float64) (float64, bool) {
@@ -2,8 +2,10 @@ package arithmeticcoding
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"github.com/ElwinCabrera/go-compression/compressionutils"
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
 	"testing"
 )
 
@@ -57,27 +59,31 @@ func test1(t *testing.T) {
 }
 
 func test2(t *testing.T) {
-	data := []byte{'2', '1', 0x00}
+	data := []byte{'2', '1'}
 	probMap := map[uint16]float64{'1': 0.4, '2': 0.4, ENDSYMBOL: 0.2}
 
-	//Range should be [.68 , .712)
-	//bit sequence should be 0.101100 .....
-
 	res, canCompress := finiteEncodeWithProbabilityModel(&data, &probMap)
 	if !canCompress {
 		t.Fatalf("Can't compress data using arithmetic coding.")
 	}
 
-	fmt.Printf("Encoded result: %v\n", res)
-
 	decodedRes := finiteDecodeWithProbabilityModel(&res, &probMap)
 
 	if !bytes.Equal(data, decodedRes) {
 		t.Fatalf("Decoded data does not match original data. Got %v, expected %v\n", string(decodedRes), string(data))
 	}
+}
 
-	fmt.Printf("Decoded result: %v\n", string(decodedRes))
+// testSymbolMissingFromModel used to be part of test2, back when the data ended with a 0x00 byte that was the end
+// symbol. ENDSYMBOL is 256 now so a 0x00 is just a byte the model has no probability for, and it has to be refused
+func testSymbolMissingFromModel(t *testing.T) {
+	data := []byte{'2', '1', 0x00}
+	probMap := map[uint16]float64{'1': 0.4, '2': 0.4, ENDSYMBOL: 0.2}
 
+	res, canCompress := finiteEncodeWithProbabilityModel(&data, &probMap)
+	if canCompress {
+		t.Fatalf("Expected 0x00 to be refused since the model doesn't have it, got %v", res)
+	}
 }
 
 func test3(t *testing.T) {
@@ -104,8 +110,81 @@ func test3(t *testing.T) {
 }
 
 func TestEncodingDecoding(t *testing.T) {
-	//test0(t)
-	//test1(t)
+	test0(t)
+	test1(t)
 	test2(t)
+	testSymbolMissingFromModel(t)
 	test3(t)
 }
+
+func TestFiniteCompressAndDecompress(t *testing.T) {
+	testingData := [][]byte{
+		{},
+		{'A'},
+		bytes.Repeat([]byte{'A'}, 100000),
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		{'A', ' ', 'S', 'A', 'D', ' ', 'S', 'A', 'L', 'A', 'D'},
+		// way more than fits in the precision of a float64, these only work because of the chunks
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(20000, 2),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(20000, 26),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(20000, 256),
+	}
+	for i, data := range testingData {
+		compressedData, _ := finiteCompress(&data)
+		decompressedData, ok := finiteDecompress(&compressedData)
+		if !ok || !bytes.Equal(data, decompressedData) {
+			t.Fatalf("decompressed data does not match original data for dataset #%v (ok %v)", i, ok)
+		}
+	}
+}
+
+func TestFiniteCompressionRatio(t *testing.T) {
+	data := testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 16)
+	compressedData, canCompress := finiteCompress(&data)
+	// 16 equally likely symbols are 4 bits each, the chunk lengths cost a bit on top of that
+	if maxLen := len(data) / 2 * 115 / 100; !canCompress || len(compressedData) > maxLen {
+		t.Fatalf("compressed %v bytes to %v, expected at most %v", len(data), len(compressedData), maxLen)
+	}
+}
+
+func TestFiniteDecompressCorruptData(t *testing.T) {
+	data := testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(1000, 26)
+	compressedData, _ := finiteCompress(&data)
+
+	truncated := compressedData[:len(compressedData)/2]
+	if _, ok := finiteDecompress(&truncated); ok {
+		t.Fatalf("expected finiteDecompress to fail for truncated data")
+	}
+	badTable := bytes.Clone(compressedData)
+	badTable[len(binary.AppendUvarint(nil, uint64(len(data))))+2] ^= 0x7F
+	if _, ok := finiteDecompress(&badTable); ok {
+		t.Fatalf("expected finiteDecompress to fail when the probabilities don't add up to 1")
+	}
+
+	// one symbol with all of the probability codes to the same chunk however many of it there are
+	single := []byte("AAAA")
+	compressedData, _ = finiteCompress(&single)
+	forged := append(binary.AppendUvarint(nil, 1<<62), compressedData[1:]...)
+	if _, ok := finiteDecompress(&forged); ok {
+		t.Fatalf("expected finiteDecompress to fail for a forged symbol count")
+	}
+}
+
+func TestQuantizeProbabilities(t *testing.T) {
+	// lots of symbols that all get bumped up to the minimum plus one that has nearly everything
+	probabilityMap := map[uint16]float64{0: 1 - 255e-6}
+	for sym := uint16(1); sym < 256; sym++ {
+		probabilityMap[sym] = 1e-6
+	}
+	quantizedProbs := quantizeProbabilities(&probabilityMap)
+	total := uint64(0)
+	for sym, prob := range quantizedProbs {
+		if prob == 0 {
+			t.Fatalf("symbol %v was quantized to 0", sym)
+		}
+		total += prob
+	}
+	if total != finiteProbTotal {
+		t.Fatalf("quantized probabilities add up to %v instead of %v", total, finiteProbTotal)
+	}
+}
@@ -0,0 +1,146 @@
+package arithmeticcoding
+
+import (
+	"encoding/binary"
+)
+
+// Frequency table layout:
+//
+//	<format><symbol bitmap><counts>
+//	 1 byte    32 bytes     see below
+//
+// Bit (sym % 8) of bitmap byte sym / 8 is set for every symbol in the table and the counts follow in symbol order, so
+// the symbols themselves are never written out.
+//
+//	freqTableExact:  every count is a LEB128 varint (7 bits a byte, the top bit is set on every byte but the last).
+//	                 The number of symbols that were coded is the sum of the counts
+//	freqTableScaled: <num symbols uvarint><scale log byte> then the counts normalized to add up to exactly
+//	                 2^scale_log, every one stored as count-1 in scale_log bits (MSB first, padded out to a byte)
+//
+// The exact table gives the decoder the real counts, the scaled one trades a little bit of compression for a table
+// that can't grow past 1+32+10+1+480 bytes however big the data is. Compress picks whichever comes out smaller.
+const (
+	freqTableExact  byte = 0
+	freqTableScaled byte = 1
+
+	freqTableBitmapLen = 32
+	// with ENDSYMBOL on top the total still has to fit in MaxRangeTotal
+	maxScaleLog = 15
+
+	minFreqTableLen = 1 + freqTableBitmapLen
+	maxFreqTableLen = 1 + freqTableBitmapLen + 256*binary.MaxVarintLen64
+)
+
+// serializeFrequencyTable writes freqTable out as an exact table
+func serializeFrequencyTable(freqTable *map[uint16]uint64) []byte {
+	serializedTable := appendFreqTableBitmap(make([]byte, 0, minFreqTableLen+2*len(*freqTable)), freqTableExact, freqTable)
+	for sym := 0; sym < int(ENDSYMBOL); sym++ {
+		if freq, ok := (*freqTable)[uint16(sym)]; ok {
+			serializedTable = binary.AppendUvarint(serializedTable, freq)
+		}
+	}
+	return serializedTable
+}
+
+// serializeScaledFrequencyTable writes out a table that was normalized to add up to 1 << scaleLog, numSymbols is the
+// number of symbols that were coded since the scaled counts don't add up to it
+func serializeScaledFrequencyTable(scaledFreqTable *map[uint16]uint64, scaleLog uint, numSymbols uint64) []byte {
+	serializedTable := appendFreqTableBitmap(make([]byte, 0, minFreqTableLen+binary.MaxVarintLen64+1+2*len(*scaledFreqTable)), freqTableScaled, scaledFreqTable)
+	serializedTable = binary.AppendUvarint(serializedTable, numSymbols)
+	serializedTable = append(serializedTable, byte(scaleLog))
+
+	var curByte byte
+	numBits := uint(0)
+	for sym := 0; sym < int(ENDSYMBOL); sym++ {
+		freq, ok := (*scaledFreqTable)[uint16(sym)]
+		if !ok {
+			continue
+		}
+		for bitIdx := int(scaleLog) - 1; bitIdx >= 0; bitIdx-- {
+			curByte = curByte<<1 | byte((freq-1)>>bitIdx&1)
+			if numBits++; numBits%8 == 0 {
+				serializedTable = append(serializedTable, curByte)
+			}
+		}
+	}
+	if numBits%8 != 0 {
+		serializedTable = append(serializedTable, curByte<<(8-numBits%8))
+	}
+	return serializedTable
+}
+
+// deserializeFrequencyTable reads a table of either format from the front of data. It returns the table, the number
+// of symbols that were coded with it and how many bytes the table took. ok is false when data doesn't start with a
+// valid table, it never reads past the end of data
+func deserializeFrequencyTable(data []byte) (freqTable map[uint16]uint64, numSymbols uint64, tableLen int, ok bool) {
+	if len(data) < minFreqTableLen {
+		return nil, 0, 0, false
+	}
+	format := data[0]
+	bitmap := data[1:minFreqTableLen]
+	idx := minFreqTableLen
+
+	var syms []uint16
+	for sym := 0; sym < int(ENDSYMBOL); sym++ {
+		if bitmap[sym/8]&(1<<(sym%8)) != 0 {
+			syms = append(syms, uint16(sym))
+		}
+	}
+
+	freqTable = make(map[uint16]uint64, len(syms))
+	switch format {
+	case freqTableExact:
+		for _, sym := range syms {
+			freq, n := binary.Uvarint(data[idx:])
+			if n <= 0 || freq == 0 || numSymbols+freq < numSymbols {
+				return nil, 0, 0, false
+			}
+			idx += n
+			freqTable[sym] = freq
+			numSymbols += freq
+		}
+	case freqTableScaled:
+		var n int
+		numSymbols, n = binary.Uvarint(data[idx:])
+		if n <= 0 || idx+n >= len(data) {
+			return nil, 0, 0, false
+		}
+		idx += n
+		scaleLog := uint(data[idx])
+		idx++
+		countsLen := (len(syms)*int(scaleLog) + 7) / 8
+		if scaleLog > maxScaleLog || len(data)-idx < countsLen {
+			return nil, 0, 0, false
+		}
+		bitIdx := 8 * idx
+		total := uint64(0)
+		for _, sym := range syms {
+			count := uint64(0)
+			for i := uint(0); i < scaleLog; i++ {
+				count = count<<1 | uint64(data[bitIdx/8]>>(7-bitIdx%8)&1)
+				bitIdx++
+			}
+			freqTable[sym] = count + 1
+			total += count + 1
+		}
+		// NormalizeFrequencies always hits the total exactly
+		if len(syms) > 0 && total != 1<<scaleLog {
+			return nil, 0, 0, false
+		}
+		idx += countsLen
+	default:
+		return nil, 0, 0, false
+	}
+	return freqTable, numSymbols, idx, true
+}
+
+// Helpers
+
+func appendFreqTableBitmap(serializedTable []byte, format byte, freqTable *map[uint16]uint64) []byte {
+	var bitmap [freqTableBitmapLen]byte
+	for sym := range *freqTable {
+		bitmap[sym/8] |= 1 << (sym % 8)
+	}
+	serializedTable = append(serializedTable, format)
+	return append(serializedTable, bitmap[:]...)
+}
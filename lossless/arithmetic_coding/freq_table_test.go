@@ -0,0 +1,221 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"maps"
+	"math/bits"
+	"testing"
+
+	"github.com/ElwinCabrera/go-compression/compressionutils"
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+	"github.com/ElwinCabrera/go-data-structs/utils"
+)
+
+// GetSomeTestData is mostly 50MB arrays, the tables only care about how many symbols there are and how big the counts get
+func getFreqTableTestData() [][]byte {
+	testingData := [][]byte{
+		{},
+		{'A'},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		{'A', ' ', 'S', 'A', 'D', ' ', 'S', 'A', 'L', 'A', 'D'},
+		bytes.Repeat([]byte{0xFF}, 100000),
+	}
+	for _, maxByteValue := range []int{2, 26, 26 + 16, 52 + 16 + 10, 256} {
+		testingData = append(testingData, testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(1<<20, maxByteValue))
+	}
+	return testingData
+}
+
+func TestFrequencyTableRoundTrip(t *testing.T) {
+	for i, data := range getFreqTableTestData() {
+		freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+
+		exactTable := serializeFrequencyTable(freqMap)
+		freqTable, numSymbols, tableLen, ok := deserializeFrequencyTable(exactTable)
+		if !ok || tableLen != len(exactTable) || numSymbols != uint64(len(data)) || !maps.Equal(*freqMap, freqTable) {
+			t.Fatalf("exact table does not round trip for dataset #%v", i)
+		}
+
+		if len(*freqMap) == 0 {
+			continue
+		}
+		for scaleLog := uint(bits.Len(uint(len(*freqMap) - 1))); scaleLog <= maxScaleLog; scaleLog++ {
+			scaledTable, scaledFreqMap := getScaledTestTable(freqMap, scaleLog, uint64(len(data)))
+			freqTable, numSymbols, tableLen, ok = deserializeFrequencyTable(scaledTable)
+			if !ok || tableLen != len(scaledTable) || numSymbols != uint64(len(data)) || !maps.Equal(*scaledFreqMap, freqTable) {
+				t.Fatalf("table scaled to 2^%v does not round trip for dataset #%v", scaleLog, i)
+			}
+			// scale_log bits a count plus the symbol count and scale_log
+			countsLen := (len(*freqMap)*int(scaleLog) + 7) / 8
+			if expectedLen := minFreqTableLen + len(binary.AppendUvarint(nil, uint64(len(data)))) + 1 + countsLen; len(scaledTable) != expectedLen {
+				t.Fatalf("table scaled to 2^%v for dataset #%v is %v bytes, expected %v", scaleLog, i, len(scaledTable), expectedLen)
+			}
+		}
+	}
+}
+
+// The bitmap costs 32 bytes up front, which the old hex table only beats when there are a handful of symbols
+func TestFrequencyTableSize(t *testing.T) {
+	for i, data := range getFreqTableTestData() {
+		freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+		if len(*freqMap) == 0 {
+			continue
+		}
+		exactLen := len(serializeFrequencyTable(freqMap))
+		scaledTable, _ := getScaledTestTable(freqMap, 12, uint64(len(data)))
+		scaledLen := len(scaledTable)
+		hexLen := len(serializeHexFrequencyTable(freqMap))
+		unitLen := len(serializeUnitFrequencyTable(freqMap))
+		t.Logf("dataset #%v, %v symbols: exact %v bytes, scaled %v bytes, hex %v bytes, units %v bytes", i, len(*freqMap),
+			exactLen, scaledLen, hexLen, unitLen)
+		if len(*freqMap) >= 16 && (exactLen >= hexLen || exactLen >= unitLen) {
+			t.Errorf("dataset #%v: exact table is %v bytes, the old tables are %v and %v", i, exactLen, hexLen, unitLen)
+		}
+	}
+}
+
+func TestFrequencyTableCorrupt(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+	scaledTable, _ := getScaledTestTable(freqMap, 12, uint64(len(data)))
+	for _, serializedTable := range [][]byte{serializeFrequencyTable(freqMap), scaledTable} {
+		for n := 0; n < len(serializedTable); n++ {
+			if _, _, _, ok := deserializeFrequencyTable(serializedTable[:n]); ok {
+				t.Fatalf("a table cut down to %v of %v bytes was accepted", n, len(serializedTable))
+			}
+		}
+	}
+
+	badFormat := serializeFrequencyTable(freqMap)
+	badFormat[0] = 0x7F
+	if _, _, _, ok := deserializeFrequencyTable(badFormat); ok {
+		t.Fatalf("a table with an unknown format was accepted")
+	}
+	badScale := bytes.Clone(scaledTable)
+	badScale[minFreqTableLen+1] = maxScaleLog + 1
+	if _, _, _, ok := deserializeFrequencyTable(badScale); ok {
+		t.Fatalf("a table scaled past 2^%v was accepted", maxScaleLog)
+	}
+	badTotal := bytes.Clone(scaledTable)
+	badTotal[len(badTotal)-1] ^= 0x10
+	if _, _, _, ok := deserializeFrequencyTable(badTotal); ok {
+		t.Fatalf("a scaled table that doesn't add up to its scale was accepted")
+	}
+	zeroCount := serializeFrequencyTable(freqMap)
+	zeroCount[minFreqTableLen] = 0
+	if _, _, _, ok := deserializeFrequencyTable(zeroCount); ok {
+		t.Fatalf("a table with a count of 0 was accepted")
+	}
+}
+
+// Compress has to pick the table that makes the output the smallest, every other table should come out at least as big
+func TestCompressPicksSmallestTable(t *testing.T) {
+	for i, data := range getFreqTableTestData()[1:] {
+		freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+		compressedData, _ := Compress(&data)
+		decompressedData, ok := Decompress(&compressedData)
+		if !ok || !bytes.Equal(data, decompressedData) {
+			t.Fatalf("dataset #%v does not round trip", i)
+		}
+
+		exactData, _ := EncodeWithProbabilityModel(&data, freqMap, true)
+		smallestLen := len(serializeFrequencyTable(freqMap)) + len(exactData)
+		for scaleLog := uint(bits.Len(uint(len(*freqMap) - 1))); scaleLog <= maxScaleLog; scaleLog++ {
+			scaledTable, scaledFreqMap := getScaledTestTable(freqMap, scaleLog, uint64(len(data)))
+			scaledData, _ := EncodeWithProbabilityModel(&data, scaledFreqMap, true)
+			smallestLen = min(smallestLen, len(scaledTable)+len(scaledData))
+		}
+		t.Logf("dataset #%v, %v bytes: compressed to %v bytes, smallest table choice gives %v", i, len(data), len(compressedData), smallestLen)
+		// the choice is made on an estimate of the coded size, which is off by a few bytes at most
+		if len(compressedData) > smallestLen+4 {
+			t.Errorf("dataset #%v compressed to %v bytes, another table gives %v", i, len(compressedData), smallestLen)
+		}
+	}
+}
+
+// Helpers
+
+func getScaledTestTable(freqMap *map[uint16]uint64, scaleLog uint, numSymbols uint64) ([]byte, *map[uint16]uint64) {
+	scaledFreqMap, _ := compressionutils.NormalizeFrequencies(freqMap, uint64(1)<<scaleLog)
+	return serializeScaledFrequencyTable(scaledFreqMap, scaleLog, numSymbols), scaledFreqMap
+}
+
+// the tables Compress used to write, kept to compare sizes against
+
+func serializeHexFrequencyTable(freqTable *map[uint16]uint64) []byte {
+	// can handle a max of 255tb
+	var buffer bytes.Buffer
+	buffer.WriteByte(byte(len(*freqTable) - 1)) // if len is 256 -1 so that it can fit in a byte (this means that 0 is significant)
+	for symbol, freq := range *freqTable {
+
+		serializedFreqHexStr := utils.NumToHexString(freq)
+		buffer.WriteByte(byte(symbol))
+		for _, ch := range serializedFreqHexStr {
+			buffer.WriteByte(byte(ch))
+		}
+
+		//remove zeros from most significant byte (hex str is little endian so start from 0)
+		//this might not be needed because the generated hex string does not include non-significant zeros
+		for i := 0; i < len(serializedFreqHexStr); i++ {
+			bt := serializedFreqHexStr[i]
+			if bt == '0' {
+				buffer.Truncate(buffer.Len() - 1)
+			} else {
+				break
+			}
+		}
+		buffer.WriteByte(0x00)
+
+	}
+	return buffer.Bytes()
+}
+
+func serializeUnitFrequencyTable(freqTable *map[uint16]uint64) []byte {
+	// can handle a max of 255tb
+	var buffer bytes.Buffer
+	buffer.WriteByte(byte(len(*freqTable) - 1)) // if len is 256 -1 so that it can fit in a byte (this means that 0 is significant)
+	for symbol, freq := range *freqTable {
+
+		serializedFreq := uint64(0)
+
+		bytez := freq & 0x3FF
+		kb := (freq >> 10) & 0x3FF
+		mb := (freq >> 20) & 0x3FF
+		gb := (freq >> 30) & 0x3FF
+		tb := (freq >> 40) & 0x3FF
+
+		units := []uint64{bytez, kb, mb, gb, tb}
+		for i, unit := range units {
+			if unit != 0 {
+				serializedFreq |= 1 << i
+			}
+		}
+		shiftAmt := len(units)
+		for _, unit := range units {
+			if unit != 0 {
+				serializedFreq |= unit << shiftAmt
+				shiftAmt += 10
+			}
+		}
+
+		serializedFreqHexStr := utils.NumToHexString(serializedFreq)
+		buffer.WriteByte(byte(symbol))
+		for _, ch := range serializedFreqHexStr {
+			buffer.WriteByte(byte(ch))
+		}
+
+		//remove zeros from most significant byte (hex str is little endian so start from 0)
+		for i := 0; i < len(serializedFreqHexStr); i++ {
+			bt := serializedFreqHexStr[i]
+			if bt == '0' {
+				buffer.Truncate(buffer.Len() - 1)
+			} else {
+				break
+			}
+		}
+		buffer.WriteByte(0x00)
+
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,120 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Model is where the coder gets its probabilities from. Every symbol owns the range [start, end) of the cumulative
+// frequencies out of Total(), and its probability is (end - start) / Total(). After a symbol is coded the coder calls
+// Update with it, a model that learns from the data changes its ranges there. The decoder has to start from a model
+// in the same state the encoder started from, then both sides change it the same way and stay in sync.
+//
+// ENDSYMBOL is coded after the last symbol so every model has to give it a range. Total() can be at most MaxRangeTotal.
+type Model interface {
+	// Total returns the sum of all the frequencies
+	Total() uint
+	// Interval returns the range of sym. It is empty (start == end) if the model can't code sym
+	Interval(sym uint16) (start, end uint)
+	// SymbolAt returns the symbol whose range has count in it (0 <= count < Total()) along with that range. The range is
+	// empty if no symbol has count in it
+	SymbolAt(count uint) (sym uint16, start, end uint)
+	// Update is called after sym has been coded
+	Update(sym uint16)
+}
+
+// StaticModel never changes, it is what Compress uses with the frequencies it sends along with the data
+type StaticModel struct {
+	symToCumulativeFreq map[uint16]freqInterval
+	ends                []uint   // end of every range in order
+	syms                []uint16 // the symbol for each range in ends
+	total               uint
+}
+
+// NewStaticModel gives every symbol in frequencyMap a range as wide as its frequency, in symbol order so the encoder and
+// decoder always divide the ranges up the same way
+func NewStaticModel(frequencyMap *map[uint16]uint64, appendEndSymbol bool) *StaticModel {
+	return newStaticModelWithEndSymbol(frequencyMap, appendEndSymbol, ENDSYMBOL)
+}
+
+// newStaticModelWithEndSymbol is NewStaticModel for symbols that go past a byte, where ENDSYMBOL could be one of them
+func newStaticModelWithEndSymbol(frequencyMap *map[uint16]uint64, appendEndSymbol bool, endSymbol uint16) *StaticModel {
+	m := &StaticModel{symToCumulativeFreq: getCumulativeFrequenciesFromFreqMap(frequencyMap, appendEndSymbol, endSymbol)}
+	for sym, interval := range m.symToCumulativeFreq {
+		if interval.width > 0 {
+			m.syms = append(m.syms, sym)
+		}
+		m.total = max(m.total, interval.end)
+	}
+	sort.Slice(m.syms, func(i, j int) bool {
+		return m.symToCumulativeFreq[m.syms[i]].start < m.symToCumulativeFreq[m.syms[j]].start
+	})
+	for _, sym := range m.syms {
+		m.ends = append(m.ends, m.symToCumulativeFreq[sym].end)
+	}
+	return m
+}
+
+func (m *StaticModel) Total() uint {
+	return m.total
+}
+
+func (m *StaticModel) Interval(sym uint16) (uint, uint) {
+	interval := m.symToCumulativeFreq[sym]
+	return interval.start, interval.end
+}
+
+func (m *StaticModel) SymbolAt(count uint) (uint16, uint, uint) {
+	i := sort.Search(len(m.ends), func(i int) bool { return count < m.ends[i] })
+	if i == len(m.ends) {
+		return 0, 0, 0
+	}
+	interval := m.symToCumulativeFreq[m.syms[i]]
+	return m.syms[i], interval.start, interval.end
+}
+
+func (m *StaticModel) Update(uint16) {}
+
+// EncodeWithModel range codes every byte of srcData followed by ENDSYMBOL. It returns false if the model can't code one
+// of the symbols or its total is more than MaxRangeTotal
+func EncodeWithModel(srcData *[]byte, model Model) ([]byte, bool) {
+	enc := newRangeEncoder()
+	for i := 0; i <= len(*srcData); i++ {
+		sym := ENDSYMBOL
+		if i < len(*srcData) {
+			sym = uint16((*srcData)[i])
+		}
+		total := model.Total()
+		start, end := model.Interval(sym)
+		if total == 0 || total > MaxRangeTotal || end <= start || end > total {
+			return nil, false
+		}
+		enc.encodeSymbol(start, end, total)
+		model.Update(sym)
+	}
+	return enc.finish(), true
+}
+
+// DecodeWithModel decodes symbols until ENDSYMBOL. model has to be in the same state the one given to EncodeWithModel
+// was. It returns false if the data doesn't make sense for the model or ends before ENDSYMBOL shows up
+func DecodeWithModel(encodedData *[]byte, model Model) ([]byte, bool) {
+	dec := newRangeDecoder(*encodedData)
+	var decodedBuffer bytes.Buffer
+	for dec.bytesPastEnd <= maxBytesPastEnd {
+		total := model.Total()
+		if total == 0 || total > MaxRangeTotal {
+			return decodedBuffer.Bytes(), false
+		}
+		sym, start, end := model.SymbolAt(dec.scaledValue(total))
+		if end <= start || end > total {
+			return decodedBuffer.Bytes(), false
+		}
+		if sym == ENDSYMBOL {
+			return decodedBuffer.Bytes(), true
+		}
+		decodedBuffer.WriteByte(byte(sym))
+		dec.consumeSymbol(start, end, total)
+		model.Update(sym)
+	}
+	return decodedBuffer.Bytes(), false
+}
@@ -0,0 +1,81 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ElwinCabrera/go-compression/compressionutils"
+)
+
+// lowercaseModel is the kind of domain model the Model interface is for, it only knows about 'a' to 'z' (all equally
+// likely) and the end symbol
+type lowercaseModel struct {
+	updates int
+}
+
+func (m *lowercaseModel) Total() uint { return 27 }
+
+func (m *lowercaseModel) Interval(sym uint16) (uint, uint) {
+	switch {
+	case sym == ENDSYMBOL:
+		return 26, 27
+	case sym >= 'a' && sym <= 'z':
+		return uint(sym - 'a'), uint(sym-'a') + 1
+	}
+	return 0, 0
+}
+
+func (m *lowercaseModel) SymbolAt(count uint) (uint16, uint, uint) {
+	if count == 26 {
+		return ENDSYMBOL, 26, 27
+	}
+	return uint16('a' + count), count, count + 1
+}
+
+func (m *lowercaseModel) Update(uint16) { m.updates++ }
+
+func TestCustomModel(t *testing.T) {
+	data := []byte("thequickbrownfoxjumpsoverthelazydog")
+	encModel := &lowercaseModel{}
+	encoded, ok := EncodeWithModel(&data, encModel)
+	if !ok {
+		t.Fatalf("EncodeWithModel failed")
+	}
+	if encModel.updates != len(data)+1 {
+		t.Fatalf("expected %v updates (one per symbol plus the end symbol) but got %v", len(data)+1, encModel.updates)
+	}
+	decoded, ok := DecodeWithModel(&encoded, &lowercaseModel{})
+	if !ok || !bytes.Equal(data, decoded) {
+		t.Fatalf("decoded %q (ok %v) but expected %q", decoded, ok, data)
+	}
+
+	notLowercase := []byte("Hello")
+	if _, ok = EncodeWithModel(&notLowercase, &lowercaseModel{}); ok {
+		t.Fatalf("expected EncodeWithModel to fail for a symbol the model can't code")
+	}
+}
+
+func TestStaticModel(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	model := NewStaticModel(compressionutils.GetSymbolFrequencyMap(&data), true)
+	if model.Total() != uint(len(data))+1 {
+		t.Fatalf("expected a total of %v but got %v", len(data)+1, model.Total())
+	}
+
+	// every count has to map back to the symbol whose range it is in
+	for count := uint(0); count < model.Total(); count++ {
+		sym, start, end := model.SymbolAt(count)
+		if count < start || count >= end {
+			t.Fatalf("SymbolAt(%v) returned the range [%v, %v)", count, start, end)
+		}
+		if intervalStart, intervalEnd := model.Interval(sym); intervalStart != start || intervalEnd != end {
+			t.Fatalf("SymbolAt(%v) returned [%v, %v) for %v but Interval gives [%v, %v)", count, start, end, sym, intervalStart, intervalEnd)
+		}
+	}
+	if _, start, end := model.SymbolAt(model.Total()); start != end {
+		t.Fatalf("expected an empty range past the total")
+	}
+	if start, end := model.Interval('Z'); start != end {
+		t.Fatalf("expected an empty range for a symbol that isn't in the data")
+	}
+}
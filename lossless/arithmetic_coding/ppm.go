@@ -0,0 +1,271 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+)
+
+// PPM (prediction by partial matching) predicts the next byte from the bytes right before it. It keeps counts for every
+// context (the last k bytes, for k = order down to 0) it has seen and codes a symbol in the longest context that has
+// seen it before. When the context hasn't seen the symbol an escape is coded and we try the next shorter context, and
+// when even the order-0 context hasn't seen it we fall back to order -1 where every symbol (and ENDSYMBOL) is equally
+// likely.
+//
+// This is PPMC: the escape count of a context is the number of different symbols it has seen. On top of that:
+//   - exclusion: once we escape from a context, none of the symbols it has seen can be the one we are coding, so they
+//     are left out of the counts of every shorter context
+//   - update exclusion: only the context the symbol was coded in and the longer ones get their counts updated
+//
+// The compressed data is a byte with the order followed by the range coded data, which ends with ENDSYMBOL.
+
+const (
+	DefaultPPMOrder = 4
+	// MaxPPMOrder is as many bytes as fit in a context key next to the order
+	MaxPPMOrder = 7
+	// ppmMaxCount is when the counts of a context get halved, well within what the range coder allows
+	ppmMaxCount = 1 << 13
+)
+
+type ppmContext struct {
+	syms   []byte
+	counts []uint16
+	total  uint
+}
+
+type ppmModel struct {
+	order    int
+	contexts map[uint64]*ppmContext
+	history  uint64 // the last 8 bytes, the most recent one in the low byte
+	histLen  int
+
+	// excluded[sym] == exclusionGen means sym is excluded for the symbol being coded, bumping exclusionGen clears it
+	excluded     [numAdaptiveSymbols]uint32
+	exclusionGen uint32
+}
+
+func newPPMModel(order int) *ppmModel {
+	return &ppmModel{order: order, contexts: make(map[uint64]*ppmContext)}
+}
+
+// CompressPPM compresses srcData with a PPM model of the given order (1 to MaxPPMOrder). Higher orders do better on
+// bigger inputs but use more memory
+func CompressPPM(srcData *[]byte, order int) ([]byte, bool) {
+	if order < 1 || order > MaxPPMOrder {
+		return nil, false
+	}
+	m := newPPMModel(order)
+	enc := newRangeEncoder()
+	for _, bt := range *srcData {
+		m.encodeSymbol(enc, uint16(bt))
+	}
+	m.encodeSymbol(enc, ENDSYMBOL)
+
+	compressedData := append([]byte{byte(order)}, enc.finish()...)
+	return compressedData, len(compressedData) < len(*srcData)
+}
+
+// DecompressPPM returns false if the data is corrupt
+func DecompressPPM(compressedData *[]byte) ([]byte, bool) {
+	if len(*compressedData) == 0 || (*compressedData)[0] < 1 || (*compressedData)[0] > MaxPPMOrder {
+		return nil, false
+	}
+	m := newPPMModel(int((*compressedData)[0]))
+	dec := newRangeDecoder((*compressedData)[1:])
+
+	var decodedBuffer bytes.Buffer
+	for dec.bytesPastEnd <= maxBytesPastEnd {
+		sym, ok := m.decodeSymbol(dec)
+		if !ok {
+			return decodedBuffer.Bytes(), false
+		}
+		if sym == ENDSYMBOL {
+			return decodedBuffer.Bytes(), true
+		}
+		decodedBuffer.WriteByte(byte(sym))
+	}
+	return decodedBuffer.Bytes(), false
+}
+
+func (m *ppmModel) encodeSymbol(enc *rangeEncoder, sym uint16) {
+	m.exclusionGen++
+	for order := min(m.order, m.histLen); order >= 0; order-- {
+		ctx := m.contexts[m.contextKey(order)]
+		if ctx == nil {
+			continue
+		}
+		escStart, total := m.contextTotal(ctx)
+		if total == 0 {
+			continue
+		}
+		if start, end, ok := m.symbolInterval(ctx, sym); ok {
+			enc.encodeSymbol(start, end, total)
+			m.update(sym, order)
+			return
+		}
+		enc.encodeSymbol(escStart, total, total)
+		m.exclude(ctx)
+	}
+
+	start, end, total := m.orderMinusOneInterval(sym)
+	enc.encodeSymbol(start, end, total)
+	m.update(sym, -1)
+}
+
+func (m *ppmModel) decodeSymbol(dec *rangeDecoder) (uint16, bool) {
+	m.exclusionGen++
+	for order := min(m.order, m.histLen); order >= 0; order-- {
+		ctx := m.contexts[m.contextKey(order)]
+		if ctx == nil {
+			continue
+		}
+		escStart, total := m.contextTotal(ctx)
+		if total == 0 {
+			continue
+		}
+		value := dec.scaledValue(total)
+		if value >= total {
+			return 0, false
+		}
+		if value >= escStart {
+			dec.consumeSymbol(escStart, total, total)
+			m.exclude(ctx)
+			continue
+		}
+		sym, start, end := m.symbolAt(ctx, value)
+		dec.consumeSymbol(start, end, total)
+		m.update(sym, order)
+		return sym, true
+	}
+
+	total := m.orderMinusOneTotal()
+	value := dec.scaledValue(total)
+	if value >= total {
+		return 0, false
+	}
+	start := uint(0)
+	for sym := uint16(0); sym < numAdaptiveSymbols; sym++ {
+		if m.excluded[sym] == m.exclusionGen {
+			continue
+		}
+		if value == start {
+			dec.consumeSymbol(start, start+1, total)
+			m.update(sym, -1)
+			return sym, true
+		}
+		start++
+	}
+	return 0, false
+}
+
+// contextKey packs the order and the last order bytes together so every context of every order has its own key
+func (m *ppmModel) contextKey(order int) uint64 {
+	if order == 0 {
+		return 0
+	}
+	return uint64(order)<<56 | m.history&(1<<(8*order)-1)
+}
+
+// contextTotal returns where the escape starts (the sum of the counts that aren't excluded) and the total including
+// the escape
+func (m *ppmModel) contextTotal(ctx *ppmContext) (uint, uint) {
+	escStart, numSyms := uint(0), uint(0)
+	for i, sym := range ctx.syms {
+		if m.excluded[sym] != m.exclusionGen {
+			escStart += uint(ctx.counts[i])
+			numSyms++
+		}
+	}
+	return escStart, escStart + numSyms
+}
+
+func (m *ppmModel) symbolInterval(ctx *ppmContext, sym uint16) (uint, uint, bool) {
+	start := uint(0)
+	for i, ctxSym := range ctx.syms {
+		if m.excluded[ctxSym] == m.exclusionGen {
+			continue
+		}
+		if uint16(ctxSym) == sym {
+			return start, start + uint(ctx.counts[i]), true
+		}
+		start += uint(ctx.counts[i])
+	}
+	return 0, 0, false
+}
+
+// symbolAt finds the symbol for a value that is below the escape
+func (m *ppmModel) symbolAt(ctx *ppmContext, value uint) (uint16, uint, uint) {
+	start := uint(0)
+	for i, ctxSym := range ctx.syms {
+		if m.excluded[ctxSym] == m.exclusionGen {
+			continue
+		}
+		if value < start+uint(ctx.counts[i]) {
+			return uint16(ctxSym), start, start + uint(ctx.counts[i])
+		}
+		start += uint(ctx.counts[i])
+	}
+	// can't get here since value is below the sum of the counts
+	return 0, 0, 0
+}
+
+func (m *ppmModel) exclude(ctx *ppmContext) {
+	for _, sym := range ctx.syms {
+		m.excluded[sym] = m.exclusionGen
+	}
+}
+
+// orderMinusOneInterval gives every symbol that isn't excluded a count of 1
+func (m *ppmModel) orderMinusOneInterval(sym uint16) (uint, uint, uint) {
+	start := uint(0)
+	for s := uint16(0); s < sym; s++ {
+		if m.excluded[s] != m.exclusionGen {
+			start++
+		}
+	}
+	return start, start + 1, m.orderMinusOneTotal()
+}
+
+func (m *ppmModel) orderMinusOneTotal() uint {
+	total := uint(0)
+	for sym := range m.excluded {
+		if m.excluded[sym] != m.exclusionGen {
+			total++
+		}
+	}
+	return total
+}
+
+// update counts sym in the context it was coded in and every longer one, then moves it into the history
+func (m *ppmModel) update(sym uint16, codedOrder int) {
+	if sym == ENDSYMBOL {
+		return
+	}
+	for order := max(codedOrder, 0); order <= min(m.order, m.histLen); order++ {
+		key := m.contextKey(order)
+		ctx := m.contexts[key]
+		if ctx == nil {
+			ctx = &ppmContext{}
+			m.contexts[key] = ctx
+		}
+		ctx.add(byte(sym))
+	}
+	m.history = m.history<<8 | uint64(sym)
+	m.histLen = min(m.histLen+1, MaxPPMOrder)
+}
+
+func (ctx *ppmContext) add(sym byte) {
+	i := bytes.IndexByte(ctx.syms, sym)
+	if i < 0 {
+		ctx.syms = append(ctx.syms, sym)
+		ctx.counts = append(ctx.counts, 0)
+		i = len(ctx.syms) - 1
+	}
+	ctx.counts[i]++
+	ctx.total++
+	if ctx.total > ppmMaxCount {
+		ctx.total = 0
+		for j := range ctx.counts {
+			ctx.counts[j] = (ctx.counts[j] + 1) / 2
+			ctx.total += uint(ctx.counts[j])
+		}
+	}
+}
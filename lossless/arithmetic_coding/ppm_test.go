@@ -0,0 +1,122 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+// getLogTestData makes up log lines like a service would write them
+func getLogTestData(numLines int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	levels := []string{"INFO", "INFO", "INFO", "DEBUG", "WARN", "ERROR"}
+	methods := []string{"GET", "GET", "POST", "PUT", "DELETE"}
+	paths := []string{"/api/v1/users", "/api/v1/orders", "/api/v1/items", "/healthz", "/api/v2/search"}
+	statuses := []int{200, 200, 200, 201, 204, 404, 500}
+	var buf bytes.Buffer
+	for i := 0; i < numLines; i++ {
+		fmt.Fprintf(&buf, "2024-03-%02dT%02d:%02d:%02dZ %-5s request handled method=%s path=%s/%d status=%d duration=%dms\n",
+			1+i/50000, (i/3600)%24, (i/60)%60, i%60, levels[rnd.Intn(len(levels))], methods[rnd.Intn(len(methods))],
+			paths[rnd.Intn(len(paths))], rnd.Intn(10000), statuses[rnd.Intn(len(statuses))], rnd.Intn(500))
+	}
+	return buf.Bytes()
+}
+
+// getTextTestData makes up english sentences out of a small grammar. The words come up about as unevenly as they do in
+// real text, a few short ones all the time and a long tail of the rest
+func getTextTestData(numSentences int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	subjects := []string{"the old man", "a young woman", "the children", "my neighbour", "the captain", "our teacher",
+		"the little dog", "everyone in the village", "the doctor", "her brother"}
+	verbs := []string{"walked to", "looked at", "talked about", "thought of", "came back from", "wrote a letter to",
+		"waited for", "remembered", "could not find", "was afraid of", "had never seen"}
+	objects := []string{"the house", "the river", "the market", "a stranger", "the old church", "the garden",
+		"the station", "the sea", "the mountains", "the school", "the end of the road"}
+	endings := []string{"", "", " in the morning", " after dinner", " before the rain came", " for a long time",
+		" with great care", " once again", " without saying a word", " as the sun went down"}
+	var buf bytes.Buffer
+	for i := 0; i < numSentences; i++ {
+		sentence := fmt.Sprintf("%s %s %s%s", subjects[rnd.Intn(len(subjects))], verbs[rnd.Intn(len(verbs))],
+			objects[rnd.Intn(len(objects))], endings[rnd.Intn(len(endings))])
+		if rnd.Intn(3) == 0 {
+			sentence += fmt.Sprintf(", and %s %s %s", subjects[rnd.Intn(len(subjects))], verbs[rnd.Intn(len(verbs))],
+				objects[rnd.Intn(len(objects))])
+		}
+		buf.WriteString(strings.ToUpper(sentence[:1]) + sentence[1:] + ".")
+		if rnd.Intn(6) == 0 {
+			buf.WriteString("\n\n")
+		} else {
+			buf.WriteString(" ")
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestPPMCompressAndDecompress(t *testing.T) {
+	testingData := [][]byte{
+		{},
+		{'A'},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(20000, 2),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(20000, 256),
+		getLogTestData(200),
+	}
+	for _, order := range []int{1, 2, DefaultPPMOrder, MaxPPMOrder} {
+		for i, data := range testingData {
+			compressedData, _ := CompressPPM(&data, order)
+			decompressedData, ok := DecompressPPM(&compressedData)
+			if !ok || !bytes.Equal(data, decompressedData) {
+				t.Fatalf("order %v: decompressed data does not match original data for dataset #%v (ok %v)", order, i, ok)
+			}
+		}
+	}
+}
+
+func TestPPMBitsPerChar(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		data          []byte
+		maxBitsPerChr float64
+	}{
+		{"logs", getLogTestData(5000), 2},
+		// real english prose needs a few MB of it before order-4 PPM gets down to about 2 bits/char. The made up
+		// sentences are only a few hundred phrases put together so they go a lot lower than that, the limit is set just
+		// above what they get so a model that predicts worse still shows up
+		{"text", getTextTestData(2000), 0.6},
+	} {
+		compressedData, _ := CompressPPM(&test.data, DefaultPPMOrder)
+		order0, _ := CompressAdaptive(&test.data)
+		bitsPerChar := float64(8*len(compressedData)) / float64(len(test.data))
+		t.Logf("%v: %v bytes, order-%v PPM %.3f bits/char, order-0 %.3f bits/char", test.name, len(test.data),
+			DefaultPPMOrder, bitsPerChar, float64(8*len(order0))/float64(len(test.data)))
+		if bitsPerChar > test.maxBitsPerChr {
+			t.Errorf("%v: PPM compressed to %.3f bits/char, expected at most %v", test.name, bitsPerChar, test.maxBitsPerChr)
+		}
+
+		decompressedData, ok := DecompressPPM(&compressedData)
+		if !ok || !bytes.Equal(test.data, decompressedData) {
+			t.Fatalf("%v: decompressed data does not match original data", test.name)
+		}
+	}
+}
+
+func TestPPMCorruptData(t *testing.T) {
+	data := getLogTestData(100)
+	compressedData, _ := CompressPPM(&data, DefaultPPMOrder)
+
+	badOrder := append([]byte{MaxPPMOrder + 1}, compressedData[1:]...)
+	if _, ok := DecompressPPM(&badOrder); ok {
+		t.Fatalf("expected DecompressPPM to fail for an order above %v", MaxPPMOrder)
+	}
+	truncated := compressedData[:len(compressedData)/2]
+	if _, ok := DecompressPPM(&truncated); ok {
+		t.Fatalf("expected DecompressPPM to fail for truncated data")
+	}
+	if _, ok := CompressPPM(&data, 0); ok {
+		t.Fatalf("expected CompressPPM to refuse order 0")
+	}
+}
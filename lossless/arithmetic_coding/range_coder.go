@@ -0,0 +1,129 @@
+package arithmeticcoding
+
+// The range coder works a byte at a time on a 32-bit interval instead of a bit at a time on a 16-bit one. The interval
+// is kept as low and range with range always above 2^24, so every symbol can get a width of at least range/total.
+//
+// Everything is done in uint64 so when adding to low carries out of the 32 bits the carry shows up in bit 32 instead of
+// getting lost. A byte that could still be changed by a carry can't be written out yet, so the last byte is held in
+// cache and any 0xFF bytes after it are only counted (cacheSize). Once a byte comes along that a carry can't go past,
+// the cached byte plus the carry and the 0xFF bytes (which become 0x00 with a carry) are written out.
+//
+// Total frequency limit: range/total is what a count of 1 is worth, and the remainder of that division is wasted. With
+// total <= MaxRangeTotal = 2^16 and range > 2^24 the waste is under 1/256 of the interval (less than 0.006 bits per
+// symbol). Encoding and decoding refuse totals above that.
+
+const (
+	MaxRangeTotal = 1 << 16
+	rangeTop      = 1 << 24
+	rangeInitial  = 0xFFFFFFFF
+	// maxBytesPastEnd is how far the decoder can read past the end of the data before we give up on ever seeing the
+	// end symbol. The encoder writes out all of low at the end so valid data never needs any
+	maxBytesPastEnd = 4
+)
+
+type rangeEncoder struct {
+	low       uint64 // 32 bits with the carry in bit 32
+	rng       uint64
+	cache     byte
+	cacheSize int
+	skipFirst bool
+	out       []byte
+}
+
+func newRangeEncoder() *rangeEncoder {
+	// the first byte that comes out is everything above the 32 bits of the initial interval which is always 0 (the
+	// interval is inside [0, 1) so no carry can get there), it isn't written out
+	return &rangeEncoder{rng: rangeInitial, cacheSize: 1, skipFirst: true}
+}
+
+// encodeSymbol narrows the interval to [symStart, symEnd) out of total
+func (e *rangeEncoder) encodeSymbol(symStart, symEnd, total uint) {
+	r := e.rng / uint64(total)
+	e.low += r * uint64(symStart)
+	e.rng = r * uint64(symEnd-symStart)
+	for e.rng < rangeTop {
+		e.rng <<= 8
+		e.shiftLow()
+	}
+}
+
+// shiftLow moves the top byte of low out, into the cache if a carry could still change it
+func (e *rangeEncoder) shiftLow() {
+	if uint32(e.low) < 0xFF000000 || e.low>>32 != 0 {
+		carry := byte(e.low >> 32)
+		pending := e.cache
+		for ; e.cacheSize > 0; e.cacheSize-- {
+			if e.skipFirst {
+				e.skipFirst = false
+			} else {
+				e.out = append(e.out, pending+carry)
+			}
+			pending = 0xFF
+		}
+		e.cache = byte(e.low >> 24)
+	}
+	e.cacheSize++
+	e.low = (e.low & 0x00FFFFFF) << 8
+}
+
+// finish writes out everything in low, and with it whatever is still in the cache
+func (e *rangeEncoder) finish() []byte {
+	for i := 0; i < 5; i++ {
+		e.shiftLow()
+	}
+	return e.out
+}
+
+type rangeDecoder struct {
+	code uint64
+	rng  uint64
+	in   []byte
+	pos  int
+	// refill gives the decoder the next bytes once it is done with in, when the data doesn't all come at once. The end
+	// of the data is when it returns nothing
+	refill       func() []byte
+	bytesPastEnd int
+}
+
+func newRangeDecoder(in []byte) *rangeDecoder {
+	return newRangeDecoderWithRefill(in, nil)
+}
+
+func newRangeDecoderWithRefill(in []byte, refill func() []byte) *rangeDecoder {
+	d := &rangeDecoder{rng: rangeInitial, in: in, refill: refill}
+	for i := 0; i < 4; i++ {
+		d.code = (d.code << 8) | uint64(d.nextByte())
+	}
+	return d
+}
+
+// scaledValue maps the code into [0, total) so that it can be matched against a symbols cumulative frequency. Corrupt
+// data can give a value of total or more
+func (d *rangeDecoder) scaledValue(total uint) uint {
+	return uint(d.code / (d.rng / uint64(total)))
+}
+
+// consumeSymbol does the same narrowing as the encoder did for the symbol that was just decoded
+func (d *rangeDecoder) consumeSymbol(symStart, symEnd, total uint) {
+	r := d.rng / uint64(total)
+	d.code -= r * uint64(symStart)
+	d.rng = r * uint64(symEnd-symStart)
+	for d.rng < rangeTop {
+		d.rng <<= 8
+		d.code = ((d.code << 8) | uint64(d.nextByte())) & rangeInitial
+	}
+}
+
+// nextByte returns 0 past the end of the data
+func (d *rangeDecoder) nextByte() byte {
+	if d.pos >= len(d.in) && d.refill != nil {
+		d.in, d.pos = d.refill(), 0
+	}
+	if d.pos >= len(d.in) {
+		d.bytesPastEnd++
+		return 0
+	}
+	bt := d.in[d.pos]
+	d.pos++
+	return bt
+}
@@ -0,0 +1,85 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ElwinCabrera/go-compression/compressionutils"
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+type codedSymbol struct {
+	start, end, total uint
+}
+
+func TestRangeCoderCarry(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	symbols := make([]codedSymbol, 200000)
+	for i := range symbols {
+		total := uint(rnd.Intn(MaxRangeTotal) + 1)
+		start := uint(rnd.Intn(int(total)))
+		// mostly symbols right at the top of the interval, those push low up against the 0xFF bytes and carry into them
+		if i%3 != 0 {
+			start = total - 1 - uint(rnd.Intn(int(min(total, 4))))
+		}
+		end := start + 1 + uint(rnd.Intn(int(total-start)))
+		symbols[i] = codedSymbol{start, end, total}
+	}
+
+	enc := newRangeEncoder()
+	carries := 0
+	for _, sym := range symbols {
+		enc.encodeSymbol(sym.start, sym.end, sym.total)
+		if enc.low>>32 != 0 {
+			carries++
+		}
+	}
+	encoded := enc.finish()
+	if carries == 0 {
+		t.Fatalf("no carries happened, the test data needs to be changed to cover them")
+	}
+
+	dec := newRangeDecoder(encoded)
+	for i, sym := range symbols {
+		if value := dec.scaledValue(sym.total); value < sym.start || value >= sym.end {
+			t.Fatalf("symbol #%v decoded to %v which is not in [%v, %v)", i, value, sym.start, sym.end)
+		}
+		dec.consumeSymbol(sym.start, sym.end, sym.total)
+	}
+	if dec.bytesPastEnd != 0 {
+		t.Fatalf("decoder read %v bytes past the end of the data", dec.bytesPastEnd)
+	}
+}
+
+func TestRangeCoderTotalLimit(t *testing.T) {
+	freqMap := map[uint16]uint64{'A': MaxRangeTotal, 'B': 1}
+	data := []byte("AB")
+	if _, ok := EncodeWithModel(&data, NewStaticModel(&freqMap, true)); ok {
+		t.Fatalf("expected EncodeWithModel to refuse a total over %v", MaxRangeTotal)
+	}
+}
+
+func TestScaledFrequencies(t *testing.T) {
+	// a lot more than fits in MaxRangeTotal, with a symbol that only shows up once
+	data := append(testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(300000, 200), 0xFF)
+	freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+	scaledFreqMap := getScaledFrequencyMap(freqMap, MaxRangeTotal-1)
+
+	total := uint64(0)
+	for sym, freq := range *scaledFreqMap {
+		if freq == 0 {
+			t.Fatalf("symbol %v was scaled down to 0", sym)
+		}
+		total += freq
+	}
+	if total > MaxRangeTotal-1 {
+		t.Fatalf("scaled frequencies add up to %v", total)
+	}
+
+	compressedData, _ := Compress(&data)
+	decompressedData, ok := Decompress(&compressedData)
+	if !ok || !bytes.Equal(data, decompressedData) {
+		t.Fatalf("Decompressed data does not match original data")
+	}
+}
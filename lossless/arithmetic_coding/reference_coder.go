@@ -0,0 +1,124 @@
+package arithmeticcoding
+
+import (
+	"math/big"
+	"sort"
+)
+
+// The reference coder is arithmetic coding the way it is written on paper: the interval is kept as exact fractions so
+// it never has to be renormalized, rounded or cut into chunks. It is much too slow for real data, which is fine since
+// it is only there to check the other coders against. Whatever a real coder outputs for a message, the reference
+// interval for the same message and model tells us what it should have come close to, and the shortest binary
+// fraction inside that interval is the fewest bits any arithmetic coder could have used.
+
+// referenceInterval is [low, low + width) out of denom. Keeping the three as integers with one shared denominator
+// skips the gcd big.Rat would run after every operation, which is where nearly all of the time went
+type referenceInterval struct {
+	low, width, denom *big.Int
+}
+
+func newReferenceInterval() *referenceInterval {
+	return &referenceInterval{low: big.NewInt(0), width: big.NewInt(1), denom: big.NewInt(1)}
+}
+
+func (ri *referenceInterval) lowRat() *big.Rat {
+	return new(big.Rat).SetFrac(ri.low, ri.denom)
+}
+
+func (ri *referenceInterval) high() *big.Rat {
+	return new(big.Rat).SetFrac(new(big.Int).Add(ri.low, ri.width), ri.denom)
+}
+
+// narrow makes the interval the [start, end) out of total part of itself
+func (ri *referenceInterval) narrow(start, end, total uint) {
+	bigTotal := new(big.Int).SetUint64(uint64(total))
+	ri.low.Mul(ri.low, bigTotal)
+	ri.low.Add(ri.low, new(big.Int).Mul(ri.width, new(big.Int).SetUint64(uint64(start))))
+	ri.width.Mul(ri.width, new(big.Int).SetUint64(uint64(end-start)))
+	ri.denom.Mul(ri.denom, bigTotal)
+}
+
+// referenceEncode codes srcData followed by ENDSYMBOL like EncodeWithModel does and returns the exact final interval.
+// It returns false if the model can't code one of the symbols
+func referenceEncode(srcData *[]byte, model Model) (*referenceInterval, bool) {
+	interval := newReferenceInterval()
+	for i := 0; i <= len(*srcData); i++ {
+		sym := ENDSYMBOL
+		if i < len(*srcData) {
+			sym = uint16((*srcData)[i])
+		}
+		start, end := model.Interval(sym)
+		if end <= start || end > model.Total() {
+			return nil, false
+		}
+		interval.narrow(start, end, model.Total())
+		model.Update(sym)
+	}
+	return interval, true
+}
+
+// referenceDecode decodes symbols out of the fraction code until ENDSYMBOL. maxSymbols stops it when code isn't in
+// any message the model can end
+func referenceDecode(code *big.Rat, model Model, maxSymbols int) ([]byte, bool) {
+	interval := newReferenceInterval()
+	var decoded []byte
+	for i := 0; i <= maxSymbols; i++ {
+		// where code is inside the interval scaled to [0, total), (code * denom - low) * total / width
+		scaled := new(big.Int).Mul(code.Num(), interval.denom)
+		scaled.Sub(scaled, new(big.Int).Mul(interval.low, code.Denom()))
+		if scaled.Sign() < 0 {
+			return decoded, false
+		}
+		scaled.Mul(scaled, new(big.Int).SetUint64(uint64(model.Total())))
+		count := scaled.Quo(scaled, new(big.Int).Mul(interval.width, code.Denom()))
+		if !count.IsUint64() || count.Uint64() >= uint64(model.Total()) {
+			return decoded, false
+		}
+
+		sym, start, end := model.SymbolAt(uint(count.Uint64()))
+		if end <= start {
+			return decoded, false
+		}
+		if sym == ENDSYMBOL {
+			return decoded, true
+		}
+		decoded = append(decoded, byte(sym))
+		interval.narrow(start, end, model.Total())
+		model.Update(sym)
+	}
+	return decoded, false
+}
+
+// shortestFraction returns the binary fraction with the fewest bits in [low, high) as bytes (most significant bit
+// first, padded with 0s) along with the number of bits it takes
+func (ri *referenceInterval) shortestFraction() ([]byte, int) {
+	low, high := ri.lowRat(), ri.high()
+	// ceil(low * 2^numBits) / 2^numBits is the smallest numBits bit fraction that isn't below low
+	fractionAt := func(numBits int) (*big.Int, bool) {
+		scale := new(big.Int).Lsh(big.NewInt(1), uint(numBits))
+		scaledLow := new(big.Rat).Mul(low, new(big.Rat).SetInt(scale))
+		fraction, rem := new(big.Int).QuoRem(scaledLow.Num(), scaledLow.Denom(), new(big.Int))
+		if rem.Sign() > 0 {
+			fraction.Add(fraction, big.NewInt(1))
+		}
+		return fraction, new(big.Rat).SetFrac(fraction, scale).Cmp(high) < 0
+	}
+	// a fraction that fits with some number of bits still fits with more, and once 2^-numBits is no bigger than the
+	// width there is always one, so binary search up to that
+	maxBits := max(0, ri.denom.BitLen()-ri.width.BitLen()+1)
+	numBits := sort.Search(maxBits, func(numBits int) bool {
+		_, fits := fractionAt(numBits)
+		return fits
+	})
+	fraction, _ := fractionAt(numBits)
+	numBytes := (numBits + 7) / 8
+	fraction.Lsh(fraction, uint(8*numBytes-numBits))
+	return fraction.FillBytes(make([]byte, numBytes)), numBits
+}
+
+// referenceFractionFromBytes reads data as the binary fraction 0.data, the way the range coder's output is meant to be
+// read
+func referenceFractionFromBytes(data []byte) *big.Rat {
+	denom := new(big.Int).Lsh(big.NewInt(1), uint(8*len(data)))
+	return new(big.Rat).SetFrac(new(big.Int).SetBytes(data), denom)
+}
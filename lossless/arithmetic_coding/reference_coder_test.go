@@ -0,0 +1,136 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ElwinCabrera/go-compression/compressionutils"
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+	bitstructs "github.com/ElwinCabrera/go-data-structs/bit-structs"
+)
+
+// the reference coder is slow so these stay small
+func getReferenceTestData() [][]byte {
+	return [][]byte{
+		{},
+		{'A'},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		{'A', ' ', 'S', 'A', 'D', ' ', 'S', 'A', 'L', 'A', 'D'},
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(2000, 2),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(1000, 26),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(500, 256),
+	}
+}
+
+func getReferenceTestModels(data []byte) map[string]func() Model {
+	return map[string]func() Model{
+		"static":   func() Model { return NewStaticModel(compressionutils.GetSymbolFrequencyMap(&data), true) },
+		"adaptive": func() Model { return NewAdaptiveModel() },
+	}
+}
+
+func TestReferenceCoderRoundTrip(t *testing.T) {
+	for i, data := range getReferenceTestData() {
+		for name, newModel := range getReferenceTestModels(data) {
+			interval, ok := referenceEncode(&data, newModel())
+			if !ok {
+				t.Fatalf("%v model: could not encode dataset #%v", name, i)
+			}
+			code, _ := interval.shortestFraction()
+			decoded, ok := referenceDecode(referenceFractionFromBytes(code), newModel(), len(data))
+			if !ok || !bytes.Equal(data, decoded) {
+				t.Fatalf("%v model: decoded data does not match original data for dataset #%v (ok %v)", name, i, ok)
+			}
+		}
+	}
+}
+
+// The range coder has to decode to the same data and can only be a little bigger than the exact code. What it loses
+// is the remainder of range / total on every symbol (under 1/256 of the interval) plus the 4 bytes it flushes at the end
+func TestRangeCoderAgainstReference(t *testing.T) {
+	for i, data := range getReferenceTestData() {
+		for name, newModel := range getReferenceTestModels(data) {
+			interval, _ := referenceEncode(&data, newModel())
+			_, referenceBits := interval.shortestFraction()
+
+			encoded, ok := EncodeWithModel(&data, newModel())
+			if !ok {
+				t.Fatalf("%v model: range coder could not encode dataset #%v", name, i)
+			}
+			decoded, ok := DecodeWithModel(&encoded, newModel())
+			if !ok || !bytes.Equal(data, decoded) {
+				t.Fatalf("%v model: range coder does not round trip dataset #%v", name, i)
+			}
+
+			rangeBits := 8 * len(encoded)
+			t.Logf("%v model, dataset #%v: range coder %v bits, exact %v bits", name, i, rangeBits, referenceBits)
+			if maxBits := referenceBits + 40 + int(math.Ceil(float64(len(data)+1)*0.006)); rangeBits > maxBits {
+				t.Errorf("%v model: range coder used %v bits for dataset #%v, the exact coder needs %v", name, rangeBits, i, referenceBits)
+			}
+		}
+	}
+}
+
+// The finite coder works on float64s, so every chunk it codes is checked against the exact interval for the same
+// symbols. The float interval has to stay within rounding distance of the exact one and the fraction it writes out has
+// to be inside the exact interval, otherwise an exact decoder would read something else out of it
+func TestFiniteCoderAgainstReference(t *testing.T) {
+	for i, data := range getReferenceTestData()[1:] {
+		freqMap := compressionutils.GetSymbolFrequencyMap(&data)
+		quantizedProbs := quantizeProbabilities(compressionutils.GetSymbolProbMapFromFreqMap(freqMap, len(data)))
+		model := newFiniteModel(quantizedProbs)
+		symToInterval := make(map[uint16]probInterval)
+		for j, sym := range model.syms {
+			symToInterval[sym] = model.intervals[j]
+		}
+		exactModel := NewStaticModel(&quantizedProbs, false)
+
+		whole := newReferenceInterval()
+		chunkBits := 0
+		exact := newReferenceInterval()
+		start, end := 0.0, 1.0
+		for j, bt := range data {
+			start, end = narrowInterval(start, end, symToInterval[uint16(bt)])
+			symStart, symEnd := exactModel.Interval(uint16(bt))
+			exact.narrow(symStart, symEnd, exactModel.Total())
+			whole.narrow(symStart, symEnd, exactModel.Total())
+			if end-start >= finiteMinWidth && j != len(data)-1 {
+				continue
+			}
+
+			exactLow, _ := exact.lowRat().Float64()
+			exactHigh, _ := exact.high().Float64()
+			if math.Abs(start-exactLow) > 1e-15 || math.Abs(end-exactHigh) > 1e-15 {
+				t.Fatalf("dataset #%v: float interval [%v, %v) is too far from the exact [%v, %v)", i, start, end, exactLow, exactHigh)
+			}
+			bitSeq := newFiniteTestBitSeq(start, end)
+			code, _, _ := readFraction(&bitSeq, 0)
+			exactCode := new(big.Rat).SetFloat64(code)
+			if exactCode.Cmp(exact.lowRat()) < 0 || exactCode.Cmp(exact.high()) >= 0 {
+				t.Fatalf("dataset #%v: the finite coder wrote %v which is outside of the exact interval", i, code)
+			}
+			chunkBits += bitSeq.GetNumBits()
+
+			exact = newReferenceInterval()
+			start, end = 0.0, 1.0
+		}
+
+		// the symbol count is sent instead of an end symbol, so the exact cost is just the shortest code of everything
+		_, wholeBits := whole.shortestFraction()
+		t.Logf("dataset #%v: finite coder %v bits in chunks, exact %v bits", i, chunkBits, wholeBits)
+		// every chunk costs its 6 bit length plus up to a couple of bits for ending on a whole fraction
+		numChunks := float64(wholeBits)/finiteMinWidthBits + 1
+		if maxBits := wholeBits + int(numChunks*(finiteCodeLenBits+3)); chunkBits > maxBits {
+			t.Errorf("dataset #%v: finite coder used %v bits, expected at most %v", i, chunkBits, maxBits)
+		}
+	}
+}
+
+// newFiniteTestBitSeq is the chunk the finite coder writes for [start, end)
+func newFiniteTestBitSeq(start, end float64) bitstructs.BitSequence {
+	bitSeq := bitstructs.NewDynamicBitSequence()
+	writeShortestFraction(&bitSeq, start, end)
+	return bitSeq
+}
@@ -0,0 +1,315 @@
+package arithmeticcoding
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ElwinCabrera/go-compression/compressionutils"
+)
+
+// The stream is a sequence of blocks followed by an end marker:
+//	<blockArithmetic><table_len><frequency table><byte chunks...><0x00> ... <blockEnd>
+//	     1 byte        uvarint      X bytes        1+N bytes     1 byte     1 byte
+// Every block carries its own frequency table so the model gets refreshed as the data changes over the stream, and is
+// range coded the same way Compress does it. The coded bytes are written out as they are produced in chunks of up to
+// 255 bytes, each prefixed by its length, with a zero length chunk marking the end of the block. This way neither
+// side has to know the size of the coded block up front and the decoder knows exactly where the block ends.
+
+const (
+	blockArithmetic byte = 0x01
+	blockEnd        byte = 0xFF
+)
+
+// DefaultBlockSize is how many bytes go into a block when NewWriter is used. Bigger blocks spread the cost of the
+// frequency table over more data but adapt to changes in the data slower
+const DefaultBlockSize = 64 << 10
+
+const maxChunkLen = 0xFF
+
+var ErrCorruptStream = errors.New("arithmeticcoding: corrupt stream")
+
+type Writer struct {
+	w         io.Writer
+	blockSize int
+	block     []byte
+	err       error
+	closed    bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterSize(w, DefaultBlockSize)
+}
+
+// NewWriterSize is the same as NewWriter but lets you pick how many bytes go into each block. A block size of 0 or
+// less is DefaultBlockSize
+func NewWriterSize(w io.Writer, blockSize int) *Writer {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	return &Writer{w: w, blockSize: blockSize, block: make([]byte, 0, blockSize)}
+}
+
+func (aw *Writer) Write(p []byte) (int, error) {
+	if aw.closed {
+		return 0, errors.New("arithmeticcoding: write to closed Writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		if aw.err != nil {
+			return written, aw.err
+		}
+		n := copy(aw.block[len(aw.block):aw.blockSize], p)
+		aw.block = aw.block[:len(aw.block)+n]
+		p = p[n:]
+		written += n
+		if len(aw.block) == aw.blockSize {
+			aw.err = aw.writeBlock()
+		}
+	}
+	return written, aw.err
+}
+
+// Flush encodes whatever is currently buffered as its own block
+func (aw *Writer) Flush() error {
+	if aw.err != nil {
+		return aw.err
+	}
+	if len(aw.block) > 0 {
+		aw.err = aw.writeBlock()
+	}
+	return aw.err
+}
+
+// Close flushes any buffered data and writes the end of stream marker. It does not close the underlying writer
+func (aw *Writer) Close() error {
+	if aw.closed {
+		return aw.err
+	}
+	if err := aw.Flush(); err != nil {
+		return err
+	}
+	aw.closed = true
+	_, aw.err = aw.w.Write([]byte{blockEnd})
+	return aw.err
+}
+
+func (aw *Writer) writeBlock() error {
+	freqMap := compressionutils.GetSymbolFrequencyMap(&aw.block)
+	serializedFreqTable, codingFreqMap := getSmallestFrequencyTable(freqMap, uint64(len(aw.block)))
+
+	header := make([]byte, 1, 1+binary.MaxVarintLen64+len(serializedFreqTable))
+	header[0] = blockArithmetic
+	header = binary.AppendUvarint(header, uint64(len(serializedFreqTable)))
+	header = append(header, serializedFreqTable...)
+	if _, err := aw.w.Write(header); err != nil {
+		return err
+	}
+
+	// the same scaling DecodeWithProbabilityModel does, the decoder only gets the table
+	model := NewStaticModel(getScaledFrequencyMap(codingFreqMap, MaxRangeTotal-1), true)
+	cw := &chunkedByteWriter{w: aw.w}
+	enc := newRangeEncoder()
+	for i := 0; i <= len(aw.block); i++ {
+		sym := ENDSYMBOL
+		if i < len(aw.block) {
+			sym = uint16(aw.block[i])
+		}
+		start, end := model.Interval(sym)
+		enc.encodeSymbol(start, end, model.Total())
+		// bytes the encoder already put out can't change anymore
+		if len(enc.out) >= maxChunkLen {
+			cw.write(enc.out)
+			enc.out = enc.out[:0]
+		}
+	}
+	cw.write(enc.finish())
+
+	aw.block = aw.block[:0]
+	return cw.close()
+}
+
+type Reader struct {
+	r   *bufio.Reader
+	err error
+
+	// state of the block currently being decoded
+	inBlock     bool
+	chunks      *chunkedByteReader
+	dec         *rangeDecoder
+	model       Model
+	symbolsLeft uint64
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read decodes symbols straight into p, a block is never decoded into memory as a whole
+func (ar *Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && ar.err == nil {
+		if !ar.inBlock {
+			ar.err = ar.readBlockHeader()
+			continue
+		}
+
+		sym, start, end := ar.model.SymbolAt(ar.dec.scaledValue(ar.model.Total()))
+		if ar.chunks.err != nil {
+			ar.err = ar.chunks.err
+			break
+		}
+		if end <= start || ar.dec.bytesPastEnd > maxBytesPastEnd {
+			ar.err = fmt.Errorf("%w: encoded value is outside of every symbols interval", ErrCorruptStream)
+			break
+		}
+		if sym == ENDSYMBOL {
+			if ar.symbolsLeft != 0 {
+				ar.err = fmt.Errorf("%w: block ends %v symbols short of its frequency table", ErrCorruptStream, ar.symbolsLeft)
+				break
+			}
+			ar.inBlock = false
+			ar.err = ar.chunks.skipToEnd()
+			continue
+		}
+		if ar.symbolsLeft == 0 {
+			ar.err = fmt.Errorf("%w: block has more symbols than its frequency table", ErrCorruptStream)
+			break
+		}
+		ar.symbolsLeft--
+
+		p[n] = byte(sym)
+		n++
+		ar.dec.consumeSymbol(start, end, ar.model.Total())
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, ar.err
+}
+
+func (ar *Reader) readBlockHeader() error {
+	blockType, err := ar.r.ReadByte()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if blockType == blockEnd {
+		return io.EOF
+	}
+	if blockType != blockArithmetic {
+		return fmt.Errorf("%w: unknown block type 0x%02X", ErrCorruptStream, blockType)
+	}
+
+	tableLen, err := binary.ReadUvarint(ar.r)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if tableLen < minFreqTableLen || tableLen > maxFreqTableLen {
+		return fmt.Errorf("%w: frequency table length %v is not valid", ErrCorruptStream, tableLen)
+	}
+	serializedFreqTable := make([]byte, tableLen)
+	if _, err = io.ReadFull(ar.r, serializedFreqTable); err != nil {
+		return unexpectedEOF(err)
+	}
+	freqTable, numSymbols, serializedLen, ok := deserializeFrequencyTable(serializedFreqTable)
+	if !ok || serializedLen != len(serializedFreqTable) {
+		return fmt.Errorf("%w: frequency table is not valid", ErrCorruptStream)
+	}
+
+	ar.model = NewStaticModel(getScaledFrequencyMap(&freqTable, MaxRangeTotal-1), true)
+	if ar.model.Total() > MaxRangeTotal {
+		return fmt.Errorf("%w: block frequency total %v is not valid", ErrCorruptStream, ar.model.Total())
+	}
+	ar.symbolsLeft = numSymbols
+	ar.chunks = &chunkedByteReader{r: ar.r}
+	ar.dec = newRangeDecoderWithRefill(nil, ar.chunks.nextChunk)
+	ar.inBlock = true
+	return ar.chunks.err
+}
+
+// chunkedByteWriter writes bytes out in length prefixed chunks
+type chunkedByteWriter struct {
+	w     io.Writer
+	chunk [1 + maxChunkLen]byte
+	n     int
+	err   error
+}
+
+func (cw *chunkedByteWriter) write(p []byte) {
+	for len(p) > 0 {
+		copied := copy(cw.chunk[1+cw.n:], p)
+		cw.n += copied
+		p = p[copied:]
+		if cw.n == maxChunkLen {
+			cw.flushChunk()
+		}
+	}
+}
+
+func (cw *chunkedByteWriter) flushChunk() {
+	if cw.n == 0 {
+		return
+	}
+	cw.chunk[0] = byte(cw.n)
+	if cw.err == nil {
+		_, cw.err = cw.w.Write(cw.chunk[:cw.n+1])
+	}
+	cw.n = 0
+}
+
+// close terminates the block with a zero length chunk
+func (cw *chunkedByteWriter) close() error {
+	cw.flushChunk()
+	if cw.err == nil {
+		_, cw.err = cw.w.Write([]byte{0x00})
+	}
+	return cw.err
+}
+
+type chunkedByteReader struct {
+	r     *bufio.Reader
+	chunk [maxChunkLen]byte
+	done  bool
+	err   error
+}
+
+// nextChunk reads the next chunk of the block, it returns nothing at the end of the block or if the stream ends early
+func (cr *chunkedByteReader) nextChunk() []byte {
+	if cr.done || cr.err != nil {
+		return nil
+	}
+	chunkLen, err := cr.r.ReadByte()
+	if err != nil {
+		cr.err = unexpectedEOF(err)
+		return nil
+	}
+	if chunkLen == 0 {
+		cr.done = true
+		return nil
+	}
+	if _, err = io.ReadFull(cr.r, cr.chunk[:chunkLen]); err != nil {
+		cr.err = unexpectedEOF(err)
+		return nil
+	}
+	return cr.chunk[:chunkLen]
+}
+
+// skipToEnd discards the bytes the decoder did not need and moves the reader to the start of the next block
+func (cr *chunkedByteReader) skipToEnd() error {
+	for !cr.done && cr.err == nil {
+		cr.nextChunk()
+	}
+	return cr.err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
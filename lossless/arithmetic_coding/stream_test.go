@@ -0,0 +1,84 @@
+package arithmeticcoding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+func testStreamRoundTrip(t *testing.T, testingData []byte, blockSize int) {
+	var compressed bytes.Buffer
+	aw := NewWriterSize(&compressed, blockSize)
+
+	// write in uneven chunks so that blocks don't line up with the writes
+	for start := 0; start < len(testingData); {
+		end := min(start+777, len(testingData))
+		if _, err := aw.Write(testingData[start:end]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		start = end
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	decompressed, err := io.ReadAll(NewReader(&compressed))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(testingData, decompressed) {
+		t.Fatalf("Streamed data does not match original data for block size %v", blockSize)
+	}
+}
+
+func TestStream(t *testing.T) {
+	testingData := [][]byte{
+		{},
+		{'A'},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 1),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 2),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 26),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(50000, 256),
+	}
+	for _, data := range testingData {
+		testStreamRoundTrip(t, data, 1000)
+		testStreamRoundTrip(t, data, DefaultBlockSize)
+	}
+	// a block bigger than MaxRangeTotal has its frequencies scaled down
+	testStreamRoundTrip(t, testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(300000, 26), 1<<20)
+}
+
+func TestStreamCorrupt(t *testing.T) {
+	data := testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 26)
+	var compressed bytes.Buffer
+	aw := NewWriterSize(&compressed, 4096)
+	aw.Write(data)
+	aw.Close()
+
+	// a flipped bit can decode to other symbols or fail, but it can never panic or give back the original data
+	corrupted := bytes.Clone(compressed.Bytes())
+	corrupted[len(corrupted)/2] ^= 0x10
+	decompressed, err := io.ReadAll(NewReader(bytes.NewReader(corrupted)))
+	if err == nil && bytes.Equal(data, decompressed) {
+		t.Fatal("a flipped bit in the coded data went unnoticed")
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	data := testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 26)
+	var compressed bytes.Buffer
+	aw := NewWriterSize(&compressed, 4096)
+	aw.Write(data)
+	aw.Close()
+
+	for _, cut := range []int{1, compressed.Len() / 2, compressed.Len() - 10} {
+		truncated := compressed.Bytes()[:compressed.Len()-cut]
+		if _, err := io.ReadAll(NewReader(bytes.NewReader(truncated))); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected io.ErrUnexpectedEOF for a stream missing its last %v bytes but got %v", cut, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package arithmeticcoding
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// CompressSymbols is Compress for symbols that don't fit in a byte, like the RUNA/RUNB output of
+// run_length.ZeroRunEncode. Every symbol has to be below alphabetSize, and alphabetSize itself is the end symbol so it
+// can never be mistaken for one of the data's symbols the way ENDSYMBOL could.
+//
+// Compressed data layout:
+//	<alphabet size><symbol bitmap><counts><coded data>
+//	   uvarint      see below     uvarints
+// The bitmap has a bit for every symbol in the alphabet, bit (sym % 8) of byte sym / 8, and the counts of the symbols
+// that are set follow in symbol order like the exact frequency table does it. The number of symbols that were coded
+// is the sum of the counts.
+
+func CompressSymbols(symbols []uint16, alphabetSize int) ([]byte, bool) {
+	if alphabetSize <= 0 || alphabetSize > math.MaxUint16 {
+		return nil, false
+	}
+	freqMap := make(map[uint16]uint64)
+	for _, sym := range symbols {
+		if int(sym) >= alphabetSize {
+			return nil, false
+		}
+		freqMap[sym]++
+	}
+	compressedData := serializeSymbolFrequencyTable(&freqMap, alphabetSize)
+
+	endSymbol := uint16(alphabetSize)
+	model := newStaticModelWithEndSymbol(getScaledFrequencyMap(&freqMap, MaxRangeTotal-1), true, endSymbol)
+	enc := newRangeEncoder()
+	for i := 0; i <= len(symbols); i++ {
+		sym := endSymbol
+		if i < len(symbols) {
+			sym = symbols[i]
+		}
+		start, end := model.Interval(sym)
+		if model.Total() > MaxRangeTotal || end <= start {
+			return nil, false
+		}
+		enc.encodeSymbol(start, end, model.Total())
+	}
+	return append(compressedData, enc.finish()...), true
+}
+
+// DecompressSymbols returns false when compressedData isn't something CompressSymbols made. It never decodes more
+// symbols than the counts add up to
+func DecompressSymbols(compressedData []byte) ([]uint16, bool) {
+	freqMap, alphabetSize, numSymbols, tableLen, ok := deserializeSymbolFrequencyTable(compressedData)
+	if !ok {
+		return nil, false
+	}
+	endSymbol := uint16(alphabetSize)
+	model := newStaticModelWithEndSymbol(getScaledFrequencyMap(&freqMap, MaxRangeTotal-1), true, endSymbol)
+	if model.Total() > MaxRangeTotal {
+		return nil, false
+	}
+
+	dec := newRangeDecoder(compressedData[tableLen:])
+	var symbols []uint16
+	for dec.bytesPastEnd <= maxBytesPastEnd {
+		sym, start, end := model.SymbolAt(dec.scaledValue(model.Total()))
+		if end <= start {
+			return nil, false
+		}
+		if sym == endSymbol {
+			return symbols, uint64(len(symbols)) == numSymbols
+		}
+		if uint64(len(symbols)) == numSymbols {
+			return nil, false
+		}
+		symbols = append(symbols, sym)
+		dec.consumeSymbol(start, end, model.Total())
+	}
+	return nil, false
+}
+
+// Helpers
+
+func serializeSymbolFrequencyTable(freqTable *map[uint16]uint64, alphabetSize int) []byte {
+	serializedTable := binary.AppendUvarint(nil, uint64(alphabetSize))
+	bitmap := make([]byte, (alphabetSize+7)/8)
+	for sym := range *freqTable {
+		bitmap[sym/8] |= 1 << (sym % 8)
+	}
+	serializedTable = append(serializedTable, bitmap...)
+	for sym := 0; sym < alphabetSize; sym++ {
+		if freq, ok := (*freqTable)[uint16(sym)]; ok {
+			serializedTable = binary.AppendUvarint(serializedTable, freq)
+		}
+	}
+	return serializedTable
+}
+
+// deserializeSymbolFrequencyTable never reads past the end of data, ok is false when data doesn't start with a valid
+// table
+func deserializeSymbolFrequencyTable(data []byte) (freqTable map[uint16]uint64, alphabetSize int, numSymbols uint64, tableLen int, ok bool) {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size == 0 || size > math.MaxUint16 {
+		return nil, 0, 0, 0, false
+	}
+	alphabetSize = int(size)
+	idx := n
+	bitmapLen := (alphabetSize + 7) / 8
+	if len(data)-idx < bitmapLen {
+		return nil, 0, 0, 0, false
+	}
+	bitmap := data[idx : idx+bitmapLen]
+	idx += bitmapLen
+
+	freqTable = make(map[uint16]uint64)
+	for sym := 0; sym < bitmapLen*8; sym++ {
+		if bitmap[sym/8]&(1<<(sym%8)) == 0 {
+			continue
+		}
+		freq, n := binary.Uvarint(data[idx:])
+		if sym >= alphabetSize || n <= 0 || freq == 0 || numSymbols+freq < numSymbols {
+			return nil, 0, 0, 0, false
+		}
+		idx += n
+		freqTable[uint16(sym)] = freq
+		numSymbols += freq
+	}
+	return freqTable, alphabetSize, numSymbols, idx, true
+}
@@ -0,0 +1,45 @@
+package arithmeticcoding
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestCompressSymbols(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	skewed := make([]uint16, 10000)
+	for i := range skewed {
+		skewed[i] = uint16(rnd.ExpFloat64()*20) % 300
+	}
+	for i, tc := range []struct {
+		symbols      []uint16
+		alphabetSize int
+	}{
+		{nil, 1},
+		{[]uint16{0}, 1},
+		// ENDSYMBOL is just another symbol here
+		{[]uint16{ENDSYMBOL, 0, ENDSYMBOL, 255, ENDSYMBOL}, 257},
+		{[]uint16{4095, 4095, 0}, 4096},
+		{skewed, 300},
+	} {
+		compressedData, ok := CompressSymbols(tc.symbols, tc.alphabetSize)
+		if !ok {
+			t.Fatalf("case #%v: CompressSymbols failed", i)
+		}
+		symbols, ok := DecompressSymbols(compressedData)
+		if !ok || !slices.Equal(tc.symbols, symbols) {
+			t.Fatalf("case #%v does not round trip, got %v", i, symbols)
+		}
+	}
+
+	if _, ok := CompressSymbols([]uint16{257}, 257); ok {
+		t.Fatal("expected CompressSymbols to refuse a symbol outside the alphabet")
+	}
+	compressedData, _ := CompressSymbols(skewed, 300)
+	for _, n := range []int{0, 1, 30, len(compressedData) / 2, len(compressedData) - 8} {
+		if _, ok := DecompressSymbols(compressedData[:n]); ok {
+			t.Fatalf("expected DecompressSymbols to fail on data cut down to %v bytes", n)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Adaptive huffman coding (Vitter's algorithm, "Algorithm Λ") starts out with a tree that only has the NYT (not yet
+// transmitted) node in it and updates the tree after every symbol, the decoder does the exact same updates so it always
+// has the same tree as the encoder. That means there is no code table in the output and the data only has to be read
+// once, which is what you want for small messages where the table would be most of the output.
+//
+// A symbol that is already in the tree is sent as its code. A new symbol is sent as the code of the NYT node followed by
+// the 8 bits of the symbol. The end of the stream is the NYT code followed by a symbol that is already in the tree, that
+// never happens otherwise so it costs nothing until we need it. The last byte is padded with zeros.
+//
+// Vitter keeps the nodes in an implicit numbering where the weights never go down as the numbers go up and within the
+// same weight the leaves come before the internal nodes. Keeping that invariant is what makes the tree stay a huffman
+// tree, and unlike FGK it also keeps the tree as shallow as possible.
+
+type adaptiveNode struct {
+	weight uint64
+	sym    byte
+	leaf   bool
+	parent *adaptiveNode
+	child  [2]*adaptiveNode
+	order  int // index in adaptiveTree.nodes
+}
+
+type adaptiveTree struct {
+	nyt    *adaptiveNode
+	leaves [numByteSymbols]*adaptiveNode
+	nodes  []*adaptiveNode // ordered from the highest number to the lowest, so nodes[0] is the root and the NYT is last
+}
+
+func newAdaptiveTree() *adaptiveTree {
+	nyt := &adaptiveNode{leaf: true}
+	return &adaptiveTree{nyt: nyt, nodes: []*adaptiveNode{nyt}}
+}
+
+func (t *adaptiveTree) root() *adaptiveNode {
+	return t.nodes[0]
+}
+
+// update adds one to the weight of sym (adding it to the tree if it is new) and fixes the tree up so it still satisfies
+// the invariant
+func (t *adaptiveTree) update(sym byte) {
+	var leafToIncrement *adaptiveNode
+	q := t.leaves[sym]
+	if q == nil {
+		// the NYT node becomes an internal node with a new NYT node and the new symbol as its children
+		q = t.nyt
+		leaf := &adaptiveNode{leaf: true, sym: sym, parent: q, order: len(t.nodes)}
+		nyt := &adaptiveNode{leaf: true, parent: q, order: len(t.nodes) + 1}
+		q.leaf = false
+		q.child = [2]*adaptiveNode{nyt, leaf}
+		t.nodes = append(t.nodes, leaf, nyt)
+		t.nyt = nyt
+		t.leaves[sym] = leaf
+		leafToIncrement = leaf
+	} else {
+		t.swap(q, t.blockLeader(q))
+		if q.parent != nil && q.parent.child[1-q.side()] == t.nyt {
+			// the parent has the same weight as q, so q would slide past it. Incrementing the parent first avoids that
+			leafToIncrement = q
+			q = q.parent
+		}
+	}
+	for q != nil {
+		q = t.slideAndIncrement(q)
+	}
+	if leafToIncrement != nil {
+		t.slideAndIncrement(leafToIncrement)
+	}
+}
+
+// slideAndIncrement moves p ahead of the nodes it would be out of order with once its weight goes up, increments its
+// weight and returns the next node up the tree that needs incrementing
+func (t *adaptiveTree) slideAndIncrement(p *adaptiveNode) *adaptiveNode {
+	oldParent := p.parent
+	wt := p.weight
+	for p.order > 0 {
+		next := t.nodes[p.order-1]
+		// a leaf goes ahead of the internal nodes of its weight and an internal node goes ahead of the leaves of its
+		// weight plus one
+		if (p.leaf && (next.leaf || next.weight != wt)) || (!p.leaf && (!next.leaf || next.weight != wt+1)) {
+			break
+		}
+		t.swap(p, next)
+	}
+	p.weight++
+	if p.leaf {
+		return p.parent
+	}
+	return oldParent
+}
+
+// blockLeader returns the highest numbered leaf with the same weight as the leaf n
+func (t *adaptiveTree) blockLeader(n *adaptiveNode) *adaptiveNode {
+	i := n.order
+	for i > 0 && t.nodes[i-1].leaf && t.nodes[i-1].weight == n.weight {
+		i--
+	}
+	return t.nodes[i]
+}
+
+// swap exchanges the places of a and b (and everything under them) in the tree and in the numbering
+func (t *adaptiveTree) swap(a, b *adaptiveNode) {
+	if a == b {
+		return
+	}
+	aParent, bParent := a.parent, b.parent
+	aSide, bSide := a.side(), b.side()
+	aParent.child[aSide] = b
+	bParent.child[bSide] = a
+	a.parent, b.parent = bParent, aParent
+	t.nodes[a.order], t.nodes[b.order] = b, a
+	a.order, b.order = b.order, a.order
+}
+
+func (n *adaptiveNode) side() int {
+	if n.parent.child[1] == n {
+		return 1
+	}
+	return 0
+}
+
+type AdaptiveWriter struct {
+	w      io.Writer
+	tree   *adaptiveTree
+	bw     bitWriter
+	err    error
+	closed bool
+}
+
+// NewAdaptiveWriter returns a writer that adaptive huffman codes everything written to it. Every byte that is done is
+// written out right away, Close has to be called to write the end of the stream
+func NewAdaptiveWriter(w io.Writer) *AdaptiveWriter {
+	return &AdaptiveWriter{w: w, tree: newAdaptiveTree()}
+}
+
+func (aw *AdaptiveWriter) Write(p []byte) (int, error) {
+	if aw.closed {
+		return 0, errors.New("huffman: write to closed AdaptiveWriter")
+	}
+	if aw.err != nil {
+		return 0, aw.err
+	}
+	for _, sym := range p {
+		if leaf := aw.tree.leaves[sym]; leaf != nil {
+			aw.writeCode(leaf)
+		} else {
+			aw.writeCode(aw.tree.nyt)
+			aw.bw.writeBits(uint64(sym), 8)
+		}
+		aw.tree.update(sym)
+	}
+	return len(p), aw.writeOut()
+}
+
+// Close writes the end of the stream and pads out the last byte. It does not close the underlying writer
+func (aw *AdaptiveWriter) Close() error {
+	if aw.closed {
+		return aw.err
+	}
+	aw.closed = true
+	if aw.err != nil {
+		return aw.err
+	}
+	// nothing written means nothing to end
+	if aw.tree.root() == aw.tree.nyt {
+		return nil
+	}
+	for sym, leaf := range aw.tree.leaves {
+		if leaf != nil {
+			aw.writeCode(aw.tree.nyt)
+			aw.bw.writeBits(uint64(sym), 8)
+			break
+		}
+	}
+	aw.bw.bytes()
+	return aw.writeOut()
+}
+
+// writeCode writes the path from the root to n
+func (aw *AdaptiveWriter) writeCode(n *adaptiveNode) {
+	var path []byte
+	for ; n.parent != nil; n = n.parent {
+		path = append(path, byte(n.side()))
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		aw.bw.writeBits(uint64(path[i]), 1)
+	}
+}
+
+// writeOut writes the bytes that are done to the underlying writer
+func (aw *AdaptiveWriter) writeOut() error {
+	if len(aw.bw.buf) == 0 {
+		return nil
+	}
+	_, aw.err = aw.w.Write(aw.bw.buf)
+	aw.bw.buf = aw.bw.buf[:0]
+	return aw.err
+}
+
+type AdaptiveReader struct {
+	br   streamBitReader
+	tree *adaptiveTree
+	err  error
+}
+
+func NewAdaptiveReader(r io.Reader) *AdaptiveReader {
+	return &AdaptiveReader{br: streamBitReader{r: bufio.NewReader(r)}, tree: newAdaptiveTree()}
+}
+
+func (ar *AdaptiveReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && ar.err == nil {
+		var sym byte
+		if sym, ar.err = ar.readSymbol(); ar.err == nil {
+			p[n] = sym
+			n++
+		}
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, ar.err
+}
+
+func (ar *AdaptiveReader) readSymbol() (byte, error) {
+	node := ar.tree.root()
+	for !node.leaf {
+		bit, err := ar.br.readBit()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		node = node.child[bit]
+	}
+
+	sym := node.sym
+	if node == ar.tree.nyt {
+		symBits, err := ar.br.readBits(8)
+		if err == io.EOF && ar.tree.root() == ar.tree.nyt {
+			// an empty stream
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		sym = byte(symBits)
+		if ar.tree.leaves[sym] != nil {
+			return 0, io.EOF
+		}
+	}
+	ar.tree.update(sym)
+	return sym, nil
+}
+
+// CompressAdaptive adaptive huffman codes data in one pass. canCompress is false when the output is not smaller than
+// the input
+func CompressAdaptive(dataToCompress *[]byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	aw := NewAdaptiveWriter(&buf)
+	aw.Write(*dataToCompress)
+	aw.Close()
+	return buf.Bytes(), buf.Len() < len(*dataToCompress)
+}
+
+func DecompressAdaptive(data *[]byte) (*[]byte, error) {
+	uncompressedData, err := io.ReadAll(NewAdaptiveReader(bytes.NewReader(*data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptData, err)
+	}
+	return &uncompressedData, nil
+}
@@ -0,0 +1,115 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ElwinCabrera/go-data-structs/trees"
+)
+
+func TestAdaptiveCompressAndDecompress(t *testing.T) {
+	for i, data := range getSmallTestData() {
+		compressedData, _ := CompressAdaptive(&data)
+		uncompressedData, err := DecompressAdaptive(&compressedData)
+		if err != nil {
+			t.Fatalf("DecompressAdaptive failed for dataset #%v: %v", i, err)
+		}
+		if !bytes.Equal(data, *uncompressedData) {
+			t.Fatalf("Decompressed data does not match original data for dataset #%v", i)
+		}
+	}
+}
+
+func TestAdaptiveTreeStaysHuffman(t *testing.T) {
+	data := getSmallTestData()[6]
+	tree := newAdaptiveTree()
+	freqMap := make(map[byte]uint64)
+	for i, sym := range data {
+		tree.update(sym)
+		freqMap[sym]++
+		if i%500 != 0 || len(freqMap) < 2 {
+			continue
+		}
+
+		// weights have to go down as the numbering goes down and every internal node weighs as much as its children
+		cost := uint64(0)
+		for order, n := range tree.nodes {
+			if order > 0 && n.weight > tree.nodes[order-1].weight {
+				t.Fatalf("after %v symbols: node %v weighs more than the node numbered above it", i+1, order)
+			}
+			if !n.leaf && n.weight != n.child[0].weight+n.child[1].weight {
+				t.Fatalf("after %v symbols: internal node %v does not weigh as much as its children", i+1, order)
+			}
+			if n.leaf && n != tree.nyt {
+				depth := uint64(0)
+				for p := n; p.parent != nil; p = p.parent {
+					depth++
+				}
+				cost += n.weight * depth
+			}
+		}
+
+		// with the NYT node in the tree the best it can do is a static tree where the least frequent symbol shares its
+		// spot with the NYT node
+		staticCost, minFreq := uint64(0), uint64(len(data))
+		for sym, bs := range trees.NewHuffmanTreeFromFrequencyMap(freqMap).GetHuffmanCodes() {
+			staticCost += freqMap[sym] * uint64(bs.GetNumBits())
+			minFreq = min(minFreq, freqMap[sym])
+		}
+		if cost != staticCost+minFreq {
+			t.Fatalf("after %v symbols: adaptive tree cost %v bits but a static huffman tree costs %v bits", i+1, cost, staticCost)
+		}
+	}
+}
+
+func TestAdaptiveStream(t *testing.T) {
+	data := getSmallTestData()[7]
+	var buf bytes.Buffer
+	aw := NewAdaptiveWriter(&buf)
+	for start := 0; start < len(data); start += 777 {
+		if _, err := aw.Write(data[start:min(start+777, len(data))]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	ar := NewAdaptiveReader(&buf)
+	uncompressedData := make([]byte, 0, len(data))
+	chunk := make([]byte, 100)
+	for {
+		n, err := ar.Read(chunk)
+		uncompressedData = append(uncompressedData, chunk[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+	if !bytes.Equal(data, uncompressedData) {
+		t.Fatalf("Decompressed data does not match original data")
+	}
+}
+
+func TestAdaptiveSmallMessages(t *testing.T) {
+	for _, msg := range []string{"A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED", `{"temp":21.5,"hum":40,"id":17}`} {
+		data := []byte(msg)
+		adaptive, _ := CompressAdaptive(&data)
+		canonical, _ := CompressCanonical(&data)
+		if len(adaptive) >= len(canonical) {
+			t.Errorf("%q: adaptive output (%v bytes) is not smaller than canonical huffman (%v bytes)", msg, len(adaptive), len(canonical))
+		}
+	}
+}
+
+func TestAdaptiveTruncated(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	compressedData, _ := CompressAdaptive(&data)
+	truncated := compressedData[:len(compressedData)-2]
+	if _, err := DecompressAdaptive(&truncated); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for truncated data but got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package huffman
+
+import (
+	"errors"
+	"io"
+)
+
+// bitWriter packs bits MSB first, which is the order canonical codes are read in
+type bitWriter struct {
+	buf     []byte
+	acc     uint64
+	numBits uint
+}
+
+// writeBits appends the lowest numBits bits of bits, most significant bit first. numBits can be at most 32
+func (bw *bitWriter) writeBits(bits uint64, numBits uint) {
+	bw.acc = (bw.acc << numBits) | (bits & (1<<numBits - 1))
+	bw.numBits += numBits
+	for bw.numBits >= 8 {
+		bw.numBits -= 8
+		bw.buf = append(bw.buf, byte(bw.acc>>bw.numBits))
+	}
+}
+
+// writeCode is writeBits for codes that can be longer than 32 bits
+func (bw *bitWriter) writeCode(code uint64, numBits uint) {
+	if numBits > 32 {
+		bw.writeBits(code>>32, numBits-32)
+		numBits = 32
+	}
+	bw.writeBits(code, numBits)
+}
+
+// bytes pads the last byte with zeros and returns everything written so far
+func (bw *bitWriter) bytes() []byte {
+	if bw.numBits > 0 {
+		bw.writeBits(0, 8-bw.numBits)
+	}
+	return bw.buf
+}
+
+var errEndOfBits = errors.New("huffman: read past the end of the data")
+
+// streamBitReader reads bits MSB first from a reader, one byte at a time so it never reads past the byte it is in
+type streamBitReader struct {
+	r       io.ByteReader
+	cur     byte
+	numBits uint // bits of cur that haven't been read yet
+}
+
+func (sr *streamBitReader) readBit() (uint64, error) {
+	if sr.numBits == 0 {
+		bt, err := sr.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		sr.cur, sr.numBits = bt, 8
+	}
+	sr.numBits--
+	return uint64(sr.cur>>sr.numBits) & 0x1, nil
+}
+
+// readBits reads numBits bits into a number. It only returns io.EOF if the stream ended before the first bit
+func (sr *streamBitReader) readBits(numBits uint) (uint64, error) {
+	bits := uint64(0)
+	for i := uint(0); i < numBits; i++ {
+		bit, err := sr.readBit()
+		if err != nil {
+			if i > 0 {
+				return 0, unexpectedEOF(err)
+			}
+			return 0, err
+		}
+		bits = bits<<1 | bit
+	}
+	return bits, nil
+}
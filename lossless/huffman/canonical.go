@@ -0,0 +1,275 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	utils2 "github.com/ElwinCabrera/go-compression/compressionutils"
+)
+
+// Canonical Huffman only keeps the code length of every symbol (see length_limited.go for how they are built). The codes themselves are then
+// handed out in order of (length, symbol), so the decoder can rebuild the exact same codes from the lengths alone and we
+// never have to send the codes (or the tree) with the compressed data.
+//
+// Compressed data layout:
+//	<num_symbols><code lengths><compressed bits padded with 0s to a full byte>
+//	  uvarint      see below        X bytes
+//
+// The code lengths of all 256 symbols are written as 4-bit nibbles (high nibble first) in the same spirit as DEFLATE:
+//	0-12     code length of the next symbol (0 means the symbol is not used)
+//	13 n     repeat the previous code length 3+n times
+//	14 h l   the next 3+(h<<4 | l) symbols are not used
+//	15 h l   code length of the next symbol is (h<<4 | l), for lengths above 12
+// The nibbles stop as soon as all 256 lengths are known and the last byte is padded with a 0 nibble if needed.
+
+const numByteSymbols = 256
+
+const (
+	maxLiteralLenNibble  = 12
+	repeatPrevLenNibble  = 13
+	repeatZeroLenNibble  = 14
+	longLenNibble        = 15
+	minRepeat            = 3
+	maxRepeat            = minRepeat + 0xF
+	maxZeroRepeat        = minRepeat + 0xFF
+	maxSupportedCodeBits = 63
+)
+
+var ErrCorruptData = errors.New("huffman: corrupt data")
+
+type canonicalCode struct {
+	code    uint64
+	numBits uint8
+}
+
+func CompressCanonical(dataToCompress *[]byte) ([]byte, bool) {
+	// every byte value fits in DefaultMaxCodeLen bit codes so this can't fail
+	compressedData, canCompress, _ := CompressCanonicalWithMaxCodeLen(dataToCompress, DefaultMaxCodeLen)
+	return compressedData, canCompress
+}
+
+// CompressCanonicalWithMaxCodeLen is CompressCanonical with control over how long a code can get. Shorter codes make
+// decoding tables smaller at the cost of a slightly worse compression ratio on skewed data
+func CompressCanonicalWithMaxCodeLen(dataToCompress *[]byte, maxCodeLen int) ([]byte, bool, error) {
+	compressedData := binary.AppendUvarint(nil, uint64(len(*dataToCompress)))
+	if len(*dataToCompress) == 0 {
+		return compressedData, false, nil
+	}
+
+	codeLengths, err := getCodeLengths(dataToCompress, maxCodeLen)
+	if err != nil {
+		return nil, false, err
+	}
+	compressedData = append(compressedData, serializeCodeLengths(&codeLengths)...)
+
+	codes := getCanonicalCodes(&codeLengths)
+	bw := bitWriter{buf: compressedData}
+	for _, bt := range *dataToCompress {
+		bw.writeCode(codes[bt].code, uint(codes[bt].numBits))
+	}
+	compressedData = bw.bytes()
+
+	return compressedData, len(compressedData) < len(*dataToCompress), nil
+}
+
+func DecompressCanonical(data *[]byte) (*[]byte, error) {
+	numSymbols, n := binary.Uvarint(*data)
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: bad symbol count", ErrCorruptData)
+	}
+	uncompressedData := make([]byte, 0)
+	if numSymbols == 0 {
+		return &uncompressedData, nil
+	}
+	// every symbol takes at least one bit
+	if numSymbols > uint64(len(*data)-n)*8 {
+		return nil, fmt.Errorf("%w: %v symbols can't fit in %v bytes", ErrCorruptData, numSymbols, len(*data)-n)
+	}
+
+	codeLengths, serializedLen, err := deserializeCodeLengths((*data)[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	uncompressedData, err = newCanonicalTableDecoder(&codeLengths).decodeSymbols((*data)[n+serializedLen:], numSymbols)
+	if err != nil {
+		return nil, err
+	}
+	return &uncompressedData, nil
+}
+
+func getCodeLengths(data *[]byte, maxCodeLen int) ([numByteSymbols]uint8, error) {
+	freqMapUint16 := utils2.GetSymbolFrequencyMap(data)
+	freqMap := make(map[byte]uint64)
+	for k, v := range *freqMapUint16 {
+		freqMap[byte(k)] = v
+	}
+	return getLengthLimitedCodeLengths(freqMap, maxCodeLen)
+}
+
+// getCanonicalCodes hands out codes in order of (length, symbol), this is the same algorithm DEFLATE uses (RFC 1951 3.2.2)
+func getCanonicalCodes(codeLengths *[numByteSymbols]uint8) [numByteSymbols]canonicalCode {
+	var numCodesOfLen [maxSupportedCodeBits + 1]uint64
+	for _, codeLen := range codeLengths {
+		if codeLen > 0 {
+			numCodesOfLen[codeLen]++
+		}
+	}
+
+	var nextCode [maxSupportedCodeBits + 1]uint64
+	code := uint64(0)
+	for numBits := 1; numBits <= maxSupportedCodeBits; numBits++ {
+		code = (code + numCodesOfLen[numBits-1]) << 1
+		nextCode[numBits] = code
+	}
+
+	var codes [numByteSymbols]canonicalCode
+	for sym, codeLen := range codeLengths {
+		if codeLen > 0 {
+			codes[sym] = canonicalCode{nextCode[codeLen], codeLen}
+			nextCode[codeLen]++
+		}
+	}
+	return codes
+}
+
+func serializeCodeLengths(codeLengths *[numByteSymbols]uint8) []byte {
+	var nibbles []byte
+
+	for i := 0; i < numByteSymbols; {
+		codeLen := codeLengths[i]
+		runLen := 1
+		for i+runLen < numByteSymbols && codeLengths[i+runLen] == codeLen {
+			runLen++
+		}
+
+		if codeLen == 0 && runLen >= minRepeat {
+			runLen = min(runLen, maxZeroRepeat)
+			nibbles = append(nibbles, repeatZeroLenNibble, byte(runLen-minRepeat)>>4, byte(runLen-minRepeat)&0xF)
+			i += runLen
+			continue
+		}
+
+		if codeLen <= maxLiteralLenNibble {
+			nibbles = append(nibbles, codeLen)
+		} else {
+			nibbles = append(nibbles, longLenNibble, codeLen>>4, codeLen&0xF)
+		}
+		i++
+		runLen--
+
+		for codeLen != 0 && runLen >= minRepeat {
+			repeat := min(runLen, maxRepeat)
+			nibbles = append(nibbles, repeatPrevLenNibble, byte(repeat-minRepeat))
+			i += repeat
+			runLen -= repeat
+		}
+	}
+
+	if len(nibbles)%2 != 0 {
+		nibbles = append(nibbles, 0)
+	}
+	serialized := make([]byte, len(nibbles)/2)
+	for i := range serialized {
+		serialized[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return serialized
+}
+
+// deserializeCodeLengths returns the code lengths and the number of bytes they took up in data
+func deserializeCodeLengths(data []byte) ([numByteSymbols]uint8, int, error) {
+	var codeLengths [numByteSymbols]uint8
+	nibbleIdx := 0
+	nextNibble := func() (byte, error) {
+		if nibbleIdx/2 >= len(data) {
+			return 0, fmt.Errorf("%w: code length table is truncated", ErrCorruptData)
+		}
+		nibble := data[nibbleIdx/2] >> 4
+		if nibbleIdx%2 == 1 {
+			nibble = data[nibbleIdx/2] & 0xF
+		}
+		nibbleIdx++
+		return nibble, nil
+	}
+
+	prevLen := uint8(0)
+	for sym := 0; sym < numByteSymbols; {
+		nibble, err := nextNibble()
+		if err != nil {
+			return codeLengths, 0, err
+		}
+
+		switch nibble {
+		case repeatPrevLenNibble, repeatZeroLenNibble:
+			n, err := nextNibble()
+			if err != nil {
+				return codeLengths, 0, err
+			}
+			if nibble == repeatZeroLenNibble {
+				l, err := nextNibble()
+				if err != nil {
+					return codeLengths, 0, err
+				}
+				n = n<<4 | l
+			}
+			repeat := int(n) + minRepeat
+			if sym+repeat > numByteSymbols {
+				return codeLengths, 0, fmt.Errorf("%w: code length run goes past the last symbol", ErrCorruptData)
+			}
+			if nibble == repeatZeroLenNibble {
+				prevLen = 0
+			} else if prevLen == 0 {
+				return codeLengths, 0, fmt.Errorf("%w: repeat of a code length that doesn't exist", ErrCorruptData)
+			}
+			for ; repeat > 0; repeat-- {
+				codeLengths[sym] = prevLen
+				sym++
+			}
+		case longLenNibble:
+			h, err := nextNibble()
+			if err != nil {
+				return codeLengths, 0, err
+			}
+			l, err := nextNibble()
+			if err != nil {
+				return codeLengths, 0, err
+			}
+			prevLen = h<<4 | l
+			codeLengths[sym] = prevLen
+			sym++
+		default:
+			prevLen = nibble
+			codeLengths[sym] = prevLen
+			sym++
+		}
+	}
+
+	if err := validateCodeLengths(&codeLengths); err != nil {
+		return codeLengths, 0, err
+	}
+	return codeLengths, (nibbleIdx + 1) / 2, nil
+}
+
+// validateCodeLengths makes sure the lengths describe a prefix code (Kraft inequality) that we are able to decode
+func validateCodeLengths(codeLengths *[numByteSymbols]uint8) error {
+	// sum of 2^-len for every code, scaled by 2^maxSupportedCodeBits so it fits in an integer
+	const kraftOne = uint64(1) << maxSupportedCodeBits
+	kraftSum := uint64(0)
+	for _, codeLen := range codeLengths {
+		if codeLen == 0 {
+			continue
+		}
+		if codeLen > maxSupportedCodeBits {
+			return fmt.Errorf("%w: code length %v is longer than %v bits", ErrCorruptData, codeLen, maxSupportedCodeBits)
+		}
+		kraftSum += kraftOne >> codeLen
+		if kraftSum > kraftOne {
+			return fmt.Errorf("%w: code lengths don't describe a prefix code", ErrCorruptData)
+		}
+	}
+	if kraftSum == 0 {
+		return fmt.Errorf("%w: code length table is empty", ErrCorruptData)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	testinguutils "github.com/ElwinCabrera/go-compression/testing_utils"
+	"github.com/ElwinCabrera/go-data-structs/trees"
+)
+
+func getSmallTestData() [][]byte {
+	return [][]byte{
+		{},
+		{'A'},
+		{0x00, 0x00, 0x00},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		{'A', ' ', 'S', 'A', 'D', ' ', 'S', 'A', 'L', 'A', 'D'},
+		testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 2),
+		testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 52+16+10),
+		testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 256),
+		getSkewedTestData(30),
+	}
+}
+
+// every symbol shows up as often as the two before it put together (fibonacci), which gives the deepest possible tree
+func getSkewedTestData(numSymbols int) []byte {
+	var data []byte
+	prev, curr := 1, 1
+	for sym := 0; sym < numSymbols; sym++ {
+		data = append(data, bytes.Repeat([]byte{byte(sym)}, prev)...)
+		prev, curr = curr, prev+curr
+	}
+	return data
+}
+
+func TestCanonicalCompressAndDecompress(t *testing.T) {
+	for i, data := range getSmallTestData() {
+		compressedData, _ := CompressCanonical(&data)
+		uncompressedData, err := DecompressCanonical(&compressedData)
+		if err != nil {
+			t.Fatalf("DecompressCanonical failed for dataset #%v: %v", i, err)
+		}
+		if !bytes.Equal(data, *uncompressedData) {
+			t.Fatalf("Decompressed data does not match original data for dataset #%v", i)
+		}
+	}
+}
+
+func TestCanonicalCodesMatchCodeLengths(t *testing.T) {
+	for i, data := range getSmallTestData() {
+		if len(data) == 0 {
+			continue
+		}
+		codeLengths, err := getCodeLengths(&data, DefaultMaxCodeLen)
+		if err != nil {
+			t.Fatalf("dataset #%v: getCodeLengths failed: %v", i, err)
+		}
+		codes := getCanonicalCodes(&codeLengths)
+
+		// same lengths as asked for and no code is a prefix of another
+		for sym, code := range codes {
+			if code.numBits != codeLengths[sym] {
+				t.Fatalf("dataset #%v: canonical code for %v has %v bits but its code length is %v", i, sym, code.numBits, codeLengths[sym])
+			}
+			for otherSym, other := range codes {
+				if sym == otherSym || code.numBits == 0 || other.numBits == 0 || other.numBits < code.numBits {
+					continue
+				}
+				if other.code>>(other.numBits-code.numBits) == code.code {
+					t.Fatalf("dataset #%v: code for %v is a prefix of the code for %v", i, sym, otherSym)
+				}
+			}
+		}
+
+		serialized := serializeCodeLengths(&codeLengths)
+		deserialized, serializedLen, err := deserializeCodeLengths(serialized)
+		if err != nil || serializedLen != len(serialized) || deserialized != codeLengths {
+			t.Fatalf("dataset #%v: code lengths did not survive serialization (err %v, len %v vs %v)", i, err, serializedLen, len(serialized))
+		}
+	}
+}
+
+func TestCanonicalHeaderIsSmaller(t *testing.T) {
+	for i, data := range getSmallTestData() {
+		if len(data) == 0 {
+			continue
+		}
+		freqMap := make(map[byte]uint64)
+		for _, bt := range data {
+			freqMap[bt]++
+		}
+		oldTable := serializeHuffmanCodes(trees.NewHuffmanTreeFromFrequencyMap(freqMap).GetHuffmanCodes())
+
+		codeLengths, _ := getCodeLengths(&data, DefaultMaxCodeLen)
+		newTable := serializeCodeLengths(&codeLengths)
+		t.Logf("dataset #%v: code table %v bytes, canonical code lengths %v bytes", i, len(oldTable), len(newTable))
+		if len(newTable) >= len(oldTable) {
+			t.Errorf("dataset #%v: canonical header (%v bytes) is not smaller than the code table (%v bytes)", i, len(newTable), len(oldTable))
+		}
+	}
+}
+
+func TestCanonicalCorruptData(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	compressedData, _ := CompressCanonical(&data)
+
+	truncated := compressedData[:len(compressedData)-2]
+	if _, err := DecompressCanonical(&truncated); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for truncated data but got %v", err)
+	}
+
+	// three symbols with 1 bit codes followed by 253 unused symbols, that can't be a prefix code
+	overSubscribed := []byte{0x05, 0x11, 0x1E, 0xFA, 0x00}
+	if _, err := DecompressCanonical(&overSubscribed); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for code lengths that aren't a prefix code but got %v", err)
+	}
+
+	// a single symbol gets the 1 bit code 0, a 1 bit isn't the start of any code
+	single := []byte{'A', 'A', 'A'}
+	compressedData, _ = CompressCanonical(&single)
+	compressedData[len(compressedData)-1] = 0xFF
+	if _, err := DecompressCanonical(&compressedData); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for bits that aren't a code but got %v", err)
+	}
+}
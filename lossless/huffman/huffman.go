@@ -4,7 +4,6 @@ import (
 	"bytes"
 	utils2 "github.com/ElwinCabrera/go-compression/compressionutils"
 	"github.com/ElwinCabrera/go-data-structs/bit-structs"
-	"github.com/ElwinCabrera/go-data-structs/trees"
 	"github.com/ElwinCabrera/go-data-structs/utils"
 )
 
@@ -18,9 +17,9 @@ func Compress(dataToCompress *[]byte) ([]byte, bool) {
 	for k, v := range *freqMapUint16 {
 		freqMap[byte(k)] = v
 	}
-	// Create a compression tree and generate the corresponding compression code for each unique byte with the weights being the frequency that each byte occurs
-	ht := trees.NewHuffmanTreeFromFrequencyMap(freqMap)
-	huffmanCodes := ht.GetHuffmanCodes()
+	// Generate the corresponding compression code for each unique byte with the weights being the frequency that each byte occurs.
+	// The codes are length limited since a plain huffman tree can get deeper than the 64 bits serializeHuffmanCodes can store
+	huffmanCodes, _ := getLengthLimitedHuffmanCodes(freqMap, DefaultMaxCodeLen)
 
 	//figure out the total bit length of the compressed data and create a new bit sequence to store the soon-to-be compressed data
 	compressedBitLen := 0
@@ -85,12 +84,10 @@ func Decompress(data *[]byte) *[]byte {
 	trailingZeroBitsToRemove := (*data)[len(*data)-1]
 	bitLen := (bitstructs.BYTE_LENGTH * len(compressedData)) - int(trailingZeroBitsToRemove)
 
-	bitSequenceOfCompressedData := bitstructs.NewBitSequenceFromByteArray(&compressedData, bitLen)
+	//decode with lookup tables instead of walking a tree rebuilt from the codes one bit at a time
+	uncompressedData := newTableDecoder(originalHuffmanCodes).decode(compressedData, bitLen)
 
-	hTree := trees.NewHuffmanTreeFromHuffmanCodes(originalHuffmanCodes)
-	uncompressedData := hTree.DecodeBitSequence(&bitSequenceOfCompressedData)
-
-	return uncompressedData
+	return &uncompressedData
 }
 
 func serializeHuffmanCodes(hc map[byte]bitstructs.BitSequence) []byte {
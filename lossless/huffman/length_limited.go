@@ -0,0 +1,125 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	bitstructs "github.com/ElwinCabrera/go-data-structs/bit-structs"
+)
+
+// A plain huffman tree can get as deep as the number of symbols when the frequencies are skewed enough (think fibonacci
+// numbers), and every decoder has some limit on how long a code can be. The package-merge algorithm
+// (Larmore & Hirschberg) builds the optimal prefix code under the constraint that no code is longer than maxCodeLen.
+//
+// It works on "coins": every symbol is a coin worth its frequency, and we have one coin of each symbol for each of the
+// maxCodeLen possible bit positions. Starting from the longest position we pair up (package) the cheapest coins two by
+// two and merge the packages in with the coins of the next position. After maxCodeLen-1 rounds the cheapest 2n-2 items
+// are picked, and the code length of a symbol is how many of its coins ended up in those items.
+
+// DefaultMaxCodeLen is the longest code DEFLATE allows
+const DefaultMaxCodeLen = 15
+
+var ErrMaxCodeLenTooSmall = errors.New("huffman: max code length is too small for the number of symbols")
+
+type coin struct {
+	weight uint64
+	sym    int // -1 for a package
+}
+
+// getLengthLimitedCodeLengths returns the code length of each symbol in freqMap so that no code is longer than maxCodeLen
+func getLengthLimitedCodeLengths(freqMap map[byte]uint64, maxCodeLen int) ([numByteSymbols]uint8, error) {
+	var codeLengths [numByteSymbols]uint8
+	if maxCodeLen < 1 || maxCodeLen > maxSupportedCodeBits {
+		return codeLengths, fmt.Errorf("huffman: max code length must be between 1 and %v but got %v", maxSupportedCodeBits, maxCodeLen)
+	}
+
+	leaves := make([]coin, 0, len(freqMap))
+	for sym, freq := range freqMap {
+		if freq > 0 {
+			leaves = append(leaves, coin{weight: freq, sym: int(sym)})
+		}
+	}
+	if len(leaves) == 0 {
+		return codeLengths, nil
+	}
+	if len(leaves) == 1 {
+		// a single symbol still needs one bit so there is something to read for it
+		codeLengths[leaves[0].sym] = 1
+		return codeLengths, nil
+	}
+	if maxCodeLen < 9 && len(leaves) > 1<<maxCodeLen {
+		return codeLengths, fmt.Errorf("%w: %v symbols can't fit in %v bit codes", ErrMaxCodeLenTooSmall, len(leaves), maxCodeLen)
+	}
+	// ties are broken by symbol so the same frequencies always give the same code lengths
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].weight != leaves[j].weight {
+			return leaves[i].weight < leaves[j].weight
+		}
+		return leaves[i].sym < leaves[j].sym
+	})
+
+	// levels[0] is the longest bit position, every level after it is its packages merged with the coins of the next one
+	levels := make([][]coin, maxCodeLen)
+	levels[0] = leaves
+	for level := 1; level < maxCodeLen; level++ {
+		prev := levels[level-1]
+		packages := make([]coin, 0, len(prev)/2)
+		for i := 0; i+1 < len(prev); i += 2 {
+			packages = append(packages, coin{weight: prev[i].weight + prev[i+1].weight, sym: -1})
+		}
+		levels[level] = mergeCoins(leaves, packages)
+	}
+
+	// Packages are always made from the front of the level before, so picking the first numItems of a level means
+	// picking the first 2*(packages picked) items of the level before it. Every coin picked adds a bit to its symbol
+	numItems := 2*len(leaves) - 2
+	for level := maxCodeLen - 1; level >= 0 && numItems > 0; level-- {
+		numPackages := 0
+		for _, item := range levels[level][:numItems] {
+			if item.sym >= 0 {
+				codeLengths[item.sym]++
+			} else {
+				numPackages++
+			}
+		}
+		numItems = 2 * numPackages
+	}
+	return codeLengths, nil
+}
+
+// mergeCoins merges two lists that are already sorted by weight, on a tie the leaf goes first
+func mergeCoins(leaves, packages []coin) []coin {
+	merged := make([]coin, 0, len(leaves)+len(packages))
+	i, j := 0, 0
+	for i < len(leaves) && j < len(packages) {
+		if leaves[i].weight <= packages[j].weight {
+			merged = append(merged, leaves[i])
+			i++
+		} else {
+			merged = append(merged, packages[j])
+			j++
+		}
+	}
+	merged = append(merged, leaves[i:]...)
+	return append(merged, packages[j:]...)
+}
+
+// getLengthLimitedHuffmanCodes gives the canonical codes for the length limited code lengths in the same form
+// trees.HuffmanTree.GetHuffmanCodes does, so they can be used with the original code table serialization
+func getLengthLimitedHuffmanCodes(freqMap map[byte]uint64, maxCodeLen int) (map[byte]bitstructs.BitSequence, error) {
+	codeLengths, err := getLengthLimitedCodeLengths(freqMap, maxCodeLen)
+	if err != nil {
+		return nil, err
+	}
+	huffmanCodes := make(map[byte]bitstructs.BitSequence)
+	for sym, code := range getCanonicalCodes(&codeLengths) {
+		if code.numBits == 0 {
+			continue
+		}
+		bs := bitstructs.NewBitSequence(int(code.numBits))
+		bs.SetBitsFromNum(0, code.code)
+		huffmanCodes[byte(sym)] = bs
+	}
+	return huffmanCodes, nil
+}
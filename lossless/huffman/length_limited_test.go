@@ -0,0 +1,132 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	testinguutils "github.com/ElwinCabrera/go-compression/testing_utils"
+	"github.com/ElwinCabrera/go-data-structs/trees"
+)
+
+func getFreqMap(data []byte) map[byte]uint64 {
+	freqMap := make(map[byte]uint64)
+	for _, bt := range data {
+		freqMap[bt]++
+	}
+	return freqMap
+}
+
+func getCodeLengthsCost(freqMap map[byte]uint64, codeLengths *[numByteSymbols]uint8) uint64 {
+	cost := uint64(0)
+	for sym, freq := range freqMap {
+		cost += freq * uint64(codeLengths[sym])
+	}
+	return cost
+}
+
+func TestLengthLimitedIsOptimalWithoutLimit(t *testing.T) {
+	for i, data := range getSmallTestData() {
+		if len(data) < 2 {
+			continue
+		}
+		freqMap := getFreqMap(data)
+
+		var treeCodeLengths [numByteSymbols]uint8
+		for sym, bs := range trees.NewHuffmanTreeFromFrequencyMap(freqMap).GetHuffmanCodes() {
+			treeCodeLengths[sym] = uint8(bs.GetNumBits())
+		}
+		codeLengths, err := getLengthLimitedCodeLengths(freqMap, maxSupportedCodeBits)
+		if err != nil {
+			t.Fatalf("dataset #%v: %v", i, err)
+		}
+
+		// the lengths can differ on ties but the compressed size has to be the same as a huffman tree gives
+		if treeCost, cost := getCodeLengthsCost(freqMap, &treeCodeLengths), getCodeLengthsCost(freqMap, &codeLengths); treeCost != cost {
+			t.Fatalf("dataset #%v: package-merge compressed size is %v bits but the huffman tree gives %v bits", i, cost, treeCost)
+		}
+	}
+}
+
+// same counts getSkewedTestData uses without having to build the data
+func getSkewedFreqMap(numSymbols int) map[byte]uint64 {
+	freqMap := make(map[byte]uint64)
+	prev, curr := uint64(1), uint64(1)
+	for sym := 0; sym < numSymbols; sym++ {
+		freqMap[byte(sym)] = prev
+		prev, curr = curr, prev+curr
+	}
+	return freqMap
+}
+
+func TestLengthLimitedCodes(t *testing.T) {
+	freqMap := getSkewedFreqMap(80) // a huffman tree would be 79 levels deep
+	for _, maxCodeLen := range []int{7, 8, 11, 12, 15, maxSupportedCodeBits} {
+		codeLengths, err := getLengthLimitedCodeLengths(freqMap, maxCodeLen)
+		if err != nil {
+			t.Fatalf("max code length %v: %v", maxCodeLen, err)
+		}
+		for sym, codeLen := range codeLengths {
+			if int(codeLen) > maxCodeLen {
+				t.Fatalf("max code length %v: symbol %v got a %v bit code", maxCodeLen, sym, codeLen)
+			}
+		}
+		if err = validateCodeLengths(&codeLengths); err != nil {
+			t.Fatalf("max code length %v: %v", maxCodeLen, err)
+		}
+	}
+
+	data := getSkewedTestData(25)
+	// 15 bits and up go past the primary decoding table, maxSupportedCodeBits leaves the codes 24 bits long
+	for _, maxCodeLen := range []int{6, 11, 15, maxSupportedCodeBits} {
+		compressedData, _, err := CompressCanonicalWithMaxCodeLen(&data, maxCodeLen)
+		if err != nil {
+			t.Fatalf("max code length %v: CompressCanonicalWithMaxCodeLen failed: %v", maxCodeLen, err)
+		}
+		uncompressedData, err := DecompressCanonical(&compressedData)
+		if err != nil || !bytes.Equal(data, *uncompressedData) {
+			t.Fatalf("max code length %v: decompressed data does not match original data (err %v)", maxCodeLen, err)
+		}
+	}
+}
+
+func TestLengthLimitedTooShort(t *testing.T) {
+	data := testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 256)
+	if _, err := getLengthLimitedCodeLengths(getFreqMap(data), 7); !errors.Is(err, ErrMaxCodeLenTooSmall) {
+		t.Fatalf("expected ErrMaxCodeLenTooSmall for 256 symbols in 7 bits but got %v", err)
+	}
+	if _, err := getLengthLimitedCodeLengths(getFreqMap(data), 8); err != nil {
+		t.Fatalf("256 symbols should fit in 8 bits but got %v", err)
+	}
+}
+
+func TestCompressWithSkewedData(t *testing.T) {
+	// a huffman tree for these counts is 79 levels deep, more than the 64 bits serializeHuffmanCodes can store, so
+	// Compress only works because its codes are length limited
+	freqMap := getSkewedFreqMap(80)
+	huffmanCodes, err := getLengthLimitedHuffmanCodes(freqMap, DefaultMaxCodeLen)
+	if err != nil {
+		t.Fatalf("getLengthLimitedHuffmanCodes failed: %v", err)
+	}
+	serializedCodes := serializeHuffmanCodes(huffmanCodes)
+	deserializedCodes, _ := deSerializeHuffmanCodesFromByteArray(&serializedCodes)
+	if len(deserializedCodes) != len(freqMap) {
+		t.Fatalf("got %v codes back from the serialized codes but expected %v", len(deserializedCodes), len(freqMap))
+	}
+	for sym, bs := range huffmanCodes {
+		if bs.GetNumBits() > DefaultMaxCodeLen {
+			t.Fatalf("symbol %v got a %v bit code", sym, bs.GetNumBits())
+		}
+		got := deserializedCodes[sym]
+		if got.GetNumBits() != bs.GetNumBits() || got.GetXBytes(8) != bs.GetXBytes(8) {
+			t.Fatalf("symbol %v: code changed going through serializeHuffmanCodes", sym)
+		}
+	}
+
+	// the data itself can't be that skewed without being huge, 25 symbols is as deep as a 24 bit code
+	data := getSkewedTestData(25)
+	compressedData, _ := Compress(&data)
+	if !bytes.Equal(data, *Decompress(&compressedData)) {
+		t.Fatalf("Decompressed data does not match original data")
+	}
+}
@@ -0,0 +1,205 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The stream is a sequence of blocks followed by an end marker:
+//	<block_type><payload_len><payload> ... <blockEnd>
+//	  1 byte     uvarint      X bytes        1 byte
+// Every huffman block is the output of CompressCanonical (or Compress for blockHuffman), so it carries its own code
+// lengths and the codes can adapt to the data as it changes over the stream. If compressing a block would make it
+// bigger we store it as is instead.
+
+const (
+	blockStored    byte = 0x00
+	blockHuffman   byte = 0x01
+	blockCanonical byte = 0x02
+	blockEnd       byte = 0xFF
+)
+
+const (
+	DefaultBlockSize = 1 << 20
+	MaxBlockSize     = 64 << 20
+)
+
+var ErrCorruptStream = errors.New("huffman: corrupt stream")
+
+type Writer struct {
+	w         io.Writer
+	blockSize int
+	block     []byte
+	err       error
+	closed    bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterSize(w, DefaultBlockSize)
+}
+
+// NewWriterSize is the same as NewWriter but lets you pick how many bytes are buffered before a block is compressed and
+// written out. Bigger blocks amortize the code table better but use more memory
+func NewWriterSize(w io.Writer, blockSize int) *Writer {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	if blockSize > MaxBlockSize {
+		blockSize = MaxBlockSize
+	}
+	return &Writer{w: w, blockSize: blockSize, block: make([]byte, 0, blockSize)}
+}
+
+func (hw *Writer) Write(p []byte) (int, error) {
+	if hw.closed {
+		return 0, errors.New("huffman: write to closed Writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		if hw.err != nil {
+			return written, hw.err
+		}
+		n := copy(hw.block[len(hw.block):hw.blockSize], p)
+		hw.block = hw.block[:len(hw.block)+n]
+		p = p[n:]
+		written += n
+		if len(hw.block) == hw.blockSize {
+			hw.err = hw.writeBlock()
+		}
+	}
+	return written, hw.err
+}
+
+// Flush compresses and writes out whatever is currently buffered as its own block
+func (hw *Writer) Flush() error {
+	if hw.err != nil {
+		return hw.err
+	}
+	if len(hw.block) > 0 {
+		hw.err = hw.writeBlock()
+	}
+	return hw.err
+}
+
+// Close flushes any buffered data and writes the end of stream marker. It does not close the underlying writer
+func (hw *Writer) Close() error {
+	if hw.closed {
+		return hw.err
+	}
+	if err := hw.Flush(); err != nil {
+		return err
+	}
+	hw.closed = true
+	_, hw.err = hw.w.Write([]byte{blockEnd})
+	return hw.err
+}
+
+func (hw *Writer) writeBlock() error {
+	blockType := blockCanonical
+	payload, canCompress := CompressCanonical(&hw.block)
+	if !canCompress || len(payload) >= len(hw.block) {
+		blockType = blockStored
+		payload = hw.block
+	}
+
+	header := make([]byte, 1, 1+binary.MaxVarintLen64)
+	header[0] = blockType
+	header = binary.AppendUvarint(header, uint64(len(payload)))
+	if _, err := hw.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := hw.w.Write(payload); err != nil {
+		return err
+	}
+	hw.block = hw.block[:0]
+	return nil
+}
+
+type Reader struct {
+	r     *bufio.Reader
+	block []byte
+	pos   int
+	err   error
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func (hr *Reader) Read(p []byte) (int, error) {
+	for hr.pos == len(hr.block) {
+		if hr.err != nil {
+			return 0, hr.err
+		}
+		hr.err = hr.readBlock()
+	}
+	n := copy(p, hr.block[hr.pos:])
+	hr.pos += n
+	return n, nil
+}
+
+func (hr *Reader) readBlock() error {
+	blockType, err := hr.r.ReadByte()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if blockType == blockEnd {
+		return io.EOF
+	}
+	if blockType != blockStored && blockType != blockHuffman && blockType != blockCanonical {
+		return fmt.Errorf("%w: unknown block type 0x%02X", ErrCorruptStream, blockType)
+	}
+
+	payloadLen, err := binary.ReadUvarint(hr.r)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	// a huffman block can be a little bigger than the data in it because of the code table, but never by this much
+	if payloadLen > 2*MaxBlockSize {
+		return fmt.Errorf("%w: block length %v is too big", ErrCorruptStream, payloadLen)
+	}
+	payload := make([]byte, payloadLen)
+	if _, err = io.ReadFull(hr.r, payload); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	hr.pos = 0
+	switch blockType {
+	case blockStored:
+		hr.block = payload
+	case blockCanonical:
+		var block *[]byte
+		if block, err = DecompressCanonical(&payload); err != nil {
+			return fmt.Errorf("%w: %v", ErrCorruptStream, err)
+		}
+		hr.block = *block
+	default:
+		hr.block, err = decompressBlock(payload)
+	}
+	return err
+}
+
+// Decompress panics on data it can't make sense of, that is fine for data we produced ourselves but a stream can come
+// from anywhere
+func decompressBlock(payload []byte) (block []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			block = nil
+			err = fmt.Errorf("%w: %v", ErrCorruptStream, r)
+		}
+	}()
+	return *Decompress(&payload), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
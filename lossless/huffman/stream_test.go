@@ -0,0 +1,67 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	testinguutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+func testStreamRoundTrip(t *testing.T, testData []byte, blockSize int) {
+	var compressed bytes.Buffer
+	hw := NewWriterSize(&compressed, blockSize)
+
+	// write in uneven chunks so that blocks don't line up with the writes
+	for start := 0; start < len(testData); {
+		end := min(start+777, len(testData))
+		if _, err := hw.Write(testData[start:end]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		start = end
+	}
+	if err := hw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	decompressed, err := io.ReadAll(NewReader(&compressed))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(testData, decompressed) {
+		t.Fatalf("Streamed data does not match original data for block size %v", blockSize)
+	}
+}
+
+func TestStream(t *testing.T) {
+	testingData := [][]byte{
+		{},
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 2),
+		testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(100000, 26),
+		testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(20000, 256),
+	}
+	for _, data := range testingData {
+		testStreamRoundTrip(t, data, 4096)
+		testStreamRoundTrip(t, data, DefaultBlockSize)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	data := testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(10000, 26)
+	var compressed bytes.Buffer
+	hw := NewWriterSize(&compressed, 4096)
+	hw.Write(data)
+	hw.Close()
+
+	truncated := compressed.Bytes()[:compressed.Len()-1] // drop the end of stream marker
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(truncated))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF for a stream without an end marker but got %v", err)
+	}
+
+	truncated = compressed.Bytes()[:compressed.Len()/2]
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(truncated))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF for a stream cut in the middle of a block but got %v", err)
+	}
+}
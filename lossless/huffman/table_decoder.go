@@ -0,0 +1,198 @@
+package huffman
+
+import (
+	"fmt"
+	"math/bits"
+
+	bitstructs "github.com/ElwinCabrera/go-data-structs/bit-structs"
+)
+
+// Walking a huffman tree costs a pointer chase (and a function call) for every single bit. Instead we look at the next
+// primaryTableBits bits of the data at once and use them as an index into a table that says which symbol those bits
+// start with and how long its code is. Codes longer than the primary table get a link to a secondary table that is
+// indexed by the bits after it, and so on, so every symbol is decoded with one table lookup per table level.
+//
+// The compressed data is stored least significant bit first within each byte, while the codes are written most
+// significant bit first, so the tables are indexed with the codes bit reversed. That way the next bits of the data are
+// always just the low bits of the bit buffer.
+
+const (
+	// primaryTableBits covers every code a DefaultMaxCodeLen limited code gives for most data
+	primaryTableBits = 10
+	// secondaryTableBits is the most bits any secondary table is indexed by, longer codes chain another table
+	secondaryTableBits = 8
+	// maxTableCodeBits is the longest code the old code table serialization can hold
+	maxTableCodeBits = 64
+)
+
+type decodeEntry struct {
+	sym     byte
+	numBits uint8        // how many bits of the code this table covers, 0 if no code starts with these bits
+	next    *decodeTable // the table for the rest of the bits when the code is longer than this table
+}
+
+type decodeTable struct {
+	bits    uint8
+	entries []decodeEntry
+}
+
+type tableCode struct {
+	sym     byte
+	code    uint64 // bit reversed and with the bits already covered by parent tables shifted out
+	numBits uint8
+}
+
+type tableDecoder struct {
+	root *decodeTable
+	// msbFirst is set when the data has its bits most significant bit first like the canonical format, every byte is
+	// bit reversed as it is read so the tables can stay the same
+	msbFirst bool
+}
+
+func newTableDecoder(huffmanCodes map[byte]bitstructs.BitSequence) *tableDecoder {
+	codes := make([]tableCode, 0, len(huffmanCodes))
+	for sym, bs := range huffmanCodes {
+		numBits := bs.GetNumBits()
+		if numBits == 0 || numBits > maxTableCodeBits {
+			// a code we can't read anything for, the tree walker can't do anything with those either
+			continue
+		}
+		// bit numBits-1 of the code is written first so it becomes bit 0 of the reversed code
+		code := uint64(0)
+		for i := 0; i < numBits; i++ {
+			if bs.GetBit(numBits - 1 - i) {
+				code |= 1 << i
+			}
+		}
+		codes = append(codes, tableCode{sym: sym, code: code, numBits: uint8(numBits)})
+	}
+	return &tableDecoder{root: buildRootDecodeTable(codes)}
+}
+
+// newCanonicalTableDecoder builds the tables for the codes getCanonicalCodes hands out for codeLengths. The lengths
+// have to have been through validateCodeLengths
+func newCanonicalTableDecoder(codeLengths *[numByteSymbols]uint8) *tableDecoder {
+	codes := make([]tableCode, 0, numByteSymbols)
+	for sym, c := range getCanonicalCodes(codeLengths) {
+		if c.numBits > 0 {
+			code := bits.Reverse64(c.code) >> (64 - c.numBits)
+			codes = append(codes, tableCode{sym: byte(sym), code: code, numBits: c.numBits})
+		}
+	}
+	return &tableDecoder{root: buildRootDecodeTable(codes), msbFirst: true}
+}
+
+func buildRootDecodeTable(codes []tableCode) *decodeTable {
+	maxCodeLen := uint8(0)
+	for _, c := range codes {
+		maxCodeLen = max(maxCodeLen, c.numBits)
+	}
+	return buildDecodeTable(codes, min(maxCodeLen, primaryTableBits))
+}
+
+// buildDecodeTable builds a table indexed by the next tableBits bits for codes, and the secondary tables for the codes
+// that don't fit in it
+func buildDecodeTable(codes []tableCode, tableBits uint8) *decodeTable {
+	table := &decodeTable{bits: tableBits, entries: make([]decodeEntry, 1<<tableBits)}
+	mask := uint64(1)<<tableBits - 1
+
+	longCodes := make(map[uint64][]tableCode)
+	for _, c := range codes {
+		if c.numBits > tableBits {
+			longCodes[c.code&mask] = append(longCodes[c.code&mask], tableCode{sym: c.sym, code: c.code >> tableBits, numBits: c.numBits - tableBits})
+			continue
+		}
+		// every index whose low bits are the code decodes to it, whatever the bits after it are
+		for idx := c.code; idx < 1<<tableBits; idx += 1 << c.numBits {
+			table.entries[idx] = decodeEntry{sym: c.sym, numBits: c.numBits}
+		}
+	}
+
+	for prefix, subCodes := range longCodes {
+		subMaxCodeLen := uint8(0)
+		for _, c := range subCodes {
+			subMaxCodeLen = max(subMaxCodeLen, c.numBits)
+		}
+		table.entries[prefix] = decodeEntry{numBits: tableBits, next: buildDecodeTable(subCodes, min(subMaxCodeLen, secondaryTableBits))}
+	}
+	return table
+}
+
+// decode decodes the first bitLen bits of data. Like the tree walker, a code that gets cut off by the end of the data
+// is dropped. Decoding stops early if the data has bits that no code starts with
+func (d *tableDecoder) decode(data []byte, bitLen int) []byte {
+	uncompressedData := make([]byte, 0, bitLen/4)
+	br := &tableBitReader{data: data, msbFirst: d.msbFirst}
+	for br.pos < bitLen {
+		sym, ok := d.decodeSymbol(br)
+		if !ok || br.pos > bitLen {
+			break
+		}
+		uncompressedData = append(uncompressedData, sym)
+	}
+	return uncompressedData
+}
+
+// decodeSymbols decodes exactly numSymbols symbols from data. It fails when data runs out first or has bits that no
+// code starts with
+func (d *tableDecoder) decodeSymbols(data []byte, numSymbols uint64) ([]byte, error) {
+	uncompressedData := make([]byte, 0, numSymbols)
+	br := &tableBitReader{data: data, msbFirst: d.msbFirst}
+	for i := uint64(0); i < numSymbols; i++ {
+		sym, ok := d.decodeSymbol(br)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid code at symbol %v", ErrCorruptData, i)
+		}
+		if br.pos > 8*len(data) {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptData, errEndOfBits)
+		}
+		uncompressedData = append(uncompressedData, sym)
+	}
+	return uncompressedData, nil
+}
+
+// decodeSymbol returns false if the next bits aren't the start of any code
+func (d *tableDecoder) decodeSymbol(br *tableBitReader) (byte, bool) {
+	table := d.root
+	for {
+		br.refill()
+		entry := table.entries[br.bitBuf&(1<<table.bits-1)]
+		if entry.numBits == 0 {
+			return 0, false
+		}
+		br.consume(entry.numBits)
+		if entry.next == nil {
+			return entry.sym, true
+		}
+		table = entry.next
+	}
+}
+
+// tableBitReader keeps the next bits of the data in the low bits of bitBuf. Past the end of the data bitBuf is padded
+// with zeros, pos going past the end is how that shows
+type tableBitReader struct {
+	data     []byte
+	msbFirst bool
+	bitBuf   uint64
+	bitCount int // bits in bitBuf that came from data
+	byteIdx  int
+	pos      int // number of bits consumed
+}
+
+func (br *tableBitReader) refill() {
+	for br.bitCount <= 56 && br.byteIdx < len(br.data) {
+		bt := br.data[br.byteIdx]
+		if br.msbFirst {
+			bt = bits.Reverse8(bt)
+		}
+		br.bitBuf |= uint64(bt) << br.bitCount
+		br.bitCount += 8
+		br.byteIdx++
+	}
+}
+
+func (br *tableBitReader) consume(numBits uint8) {
+	br.bitBuf >>= numBits
+	br.bitCount -= int(numBits)
+	br.pos += int(numBits)
+}
@@ -0,0 +1,90 @@
+package huffman
+
+import (
+	"bytes"
+	"testing"
+
+	testinguutils "github.com/ElwinCabrera/go-compression/testing_utils"
+	bitstructs "github.com/ElwinCabrera/go-data-structs/bit-structs"
+	"github.com/ElwinCabrera/go-data-structs/trees"
+)
+
+// encodeWithCodes lays the codes out the same way Compress does and returns the bytes and the number of bits used
+func encodeWithCodes(data []byte, huffmanCodes map[byte]bitstructs.BitSequence) ([]byte, int) {
+	var encoded []byte
+	bitLen := 0
+	for _, bt := range data {
+		code := huffmanCodes[bt]
+		for bitIdx := code.GetNumBits() - 1; bitIdx >= 0; bitIdx-- {
+			if bitLen%8 == 0 {
+				encoded = append(encoded, 0)
+			}
+			if code.GetBit(bitIdx) {
+				encoded[bitLen/8] |= 1 << (bitLen % 8)
+			}
+			bitLen++
+		}
+	}
+	return encoded, bitLen
+}
+
+func TestTableDecoderMatchesTreeWalker(t *testing.T) {
+	for i, data := range getSmallTestData() {
+		if len(data) < 2 {
+			continue
+		}
+		// codes straight from a huffman tree aren't canonical and the skewed data gives codes that need more than one
+		// secondary table
+		huffmanCodes := trees.NewHuffmanTreeFromFrequencyMap(getFreqMap(data)).GetHuffmanCodes()
+		encoded, bitLen := encodeWithCodes(data, huffmanCodes)
+
+		decoded := newTableDecoder(huffmanCodes).decode(encoded, bitLen)
+		if !bytes.Equal(data, decoded) {
+			t.Fatalf("dataset #%v: table decoder does not give back the original data", i)
+		}
+
+		bitSequence := bitstructs.NewBitSequenceFromByteArray(&encoded, bitLen)
+		treeDecoded := trees.NewHuffmanTreeFromHuffmanCodes(huffmanCodes).DecodeBitSequence(&bitSequence)
+		if !bytes.Equal(*treeDecoded, decoded) {
+			t.Fatalf("dataset #%v: table decoder and tree walker disagree", i)
+		}
+	}
+}
+
+func TestTableDecoderTruncatedCode(t *testing.T) {
+	data := []byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED")
+	huffmanCodes, _ := getLengthLimitedHuffmanCodes(getFreqMap(data), DefaultMaxCodeLen)
+	encoded, bitLen := encodeWithCodes(data, huffmanCodes)
+
+	// the last code is cut off so only the symbols before it come back
+	decoded := newTableDecoder(huffmanCodes).decode(encoded, bitLen-1)
+	if !bytes.Equal(data[:len(data)-1], decoded) {
+		t.Fatalf("expected %q but got %q", data[:len(data)-1], decoded)
+	}
+}
+
+func getBenchmarkData() (map[byte]bitstructs.BitSequence, []byte, int, int) {
+	data := testinguutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(1<<20, 52+16+10)
+	huffmanCodes, _ := getLengthLimitedHuffmanCodes(getFreqMap(data), DefaultMaxCodeLen)
+	encoded, bitLen := encodeWithCodes(data, huffmanCodes)
+	return huffmanCodes, encoded, bitLen, len(data)
+}
+
+func BenchmarkDecodeTable(b *testing.B) {
+	huffmanCodes, encoded, bitLen, dataLen := getBenchmarkData()
+	b.SetBytes(int64(dataLen))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newTableDecoder(huffmanCodes).decode(encoded, bitLen)
+	}
+}
+
+func BenchmarkDecodeTreeWalk(b *testing.B) {
+	huffmanCodes, encoded, bitLen, dataLen := getBenchmarkData()
+	b.SetBytes(int64(dataLen))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bitSequence := bitstructs.NewBitSequenceFromByteArray(&encoded, bitLen)
+		trees.NewHuffmanTreeFromHuffmanCodes(huffmanCodes).DecodeBitSequence(&bitSequence)
+	}
+}
@@ -0,0 +1,128 @@
+package lz77
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ElwinCabrera/go-compression/lossless/huffman"
+)
+
+// Compress doesn't huffman code the LZSS bytes as they are, literals, match lengths and offsets all have very
+// different statistics and one code for all of them would fit none of them well. The tokens are split up into streams
+// instead and every stream is compressed with huffman.CompressCanonical on its own:
+//	flags:            one bit per token, 1 for a match, MSB first
+//	literals/lengths: the literal byte, or length-MinMatchLen for a match
+//	offset high:      top byte of offset-1 for every match. Most matches are close by so this is mostly small values
+//	offset low:       bottom byte of offset-1 for every match
+//
+// Compressed data layout:
+//	<num_tokens><flags len><flags> <literals/lengths len><literals/lengths> <offset high len><...> <offset low len><...>
+//	  uvarint     uvarint  X bytes         uvarint            X bytes            uvarint               uvarint
+
+const numStreams = 4
+
+const (
+	flagsStream = iota
+	literalLenStream
+	offsetHighStream
+	offsetLowStream
+)
+
+func Compress(srcData *[]byte) ([]byte, bool) {
+	// the default window size is always valid
+	compressedData, canCompress, _ := CompressWithWindowSize(srcData, DefaultWindowSize)
+	return compressedData, canCompress
+}
+
+// CompressWithWindowSize is Compress with control over how far back a match can go. A bigger window finds more matches
+// in data that repeats itself far apart, at the cost of longer offsets. windowSize has to be between 1 and
+// MaxWindowSize. The decoder doesn't need to know the window size
+func CompressWithWindowSize(srcData *[]byte, windowSize int) ([]byte, bool, error) {
+	tokens, err := Tokenize(*srcData, windowSize)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var streams [numStreams][]byte
+	streams[flagsStream] = make([]byte, (len(tokens)+7)/8)
+	for i, token := range tokens {
+		if !token.IsMatch() {
+			streams[literalLenStream] = append(streams[literalLenStream], token.Literal)
+			continue
+		}
+		streams[flagsStream][i/8] |= 1 << (7 - i%8)
+		streams[literalLenStream] = append(streams[literalLenStream], byte(token.Length-MinMatchLen))
+		streams[offsetHighStream] = append(streams[offsetHighStream], byte((token.Offset-1)>>8))
+		streams[offsetLowStream] = append(streams[offsetLowStream], byte(token.Offset-1))
+	}
+
+	compressedData := binary.AppendUvarint(nil, uint64(len(tokens)))
+	for _, stream := range streams {
+		compressedStream, _ := huffman.CompressCanonical(&stream)
+		compressedData = binary.AppendUvarint(compressedData, uint64(len(compressedStream)))
+		compressedData = append(compressedData, compressedStream...)
+	}
+	return compressedData, len(compressedData) < len(*srcData), nil
+}
+
+func Decompress(data *[]byte) (*[]byte, error) {
+	numTokens, n := binary.Uvarint(*data)
+	// every token has a flag bit, so there can't be more tokens than bits in the data
+	if n <= 0 || numTokens > uint64(len(*data))*8 {
+		return nil, fmt.Errorf("%w: bad token count", ErrCorruptData)
+	}
+	idx := n
+	var streams [numStreams][]byte
+	for i := range streams {
+		streamLen, n := binary.Uvarint((*data)[idx:])
+		if n <= 0 || streamLen > uint64(len(*data)-idx-n) {
+			return nil, fmt.Errorf("%w: bad length for stream %v", ErrCorruptData, i)
+		}
+		idx += n
+		compressedStream := (*data)[idx : idx+int(streamLen)]
+		idx += int(streamLen)
+		stream, err := huffman.DecompressCanonical(&compressedStream)
+		if err != nil {
+			return nil, fmt.Errorf("%w: stream %v: %v", ErrCorruptData, i, err)
+		}
+		streams[i] = *stream
+	}
+	if idx != len(*data) {
+		return nil, fmt.Errorf("%w: %v bytes after the last stream", ErrCorruptData, len(*data)-idx)
+	}
+	if uint64(len(streams[flagsStream])) != (numTokens+7)/8 {
+		return nil, fmt.Errorf("%w: %v flag bytes for %v tokens", ErrCorruptData, len(streams[flagsStream]), numTokens)
+	}
+
+	numMatches := 0
+	for i := uint64(0); i < numTokens; i++ {
+		if streams[flagsStream][i/8]&(1<<(7-i%8)) != 0 {
+			numMatches++
+		}
+	}
+	if uint64(len(streams[literalLenStream])) != numTokens || len(streams[offsetHighStream]) != numMatches ||
+		len(streams[offsetLowStream]) != numMatches {
+		return nil, fmt.Errorf("%w: stream lengths don't match the flags", ErrCorruptData)
+	}
+
+	tokens := make([]Token, numTokens)
+	matchIdx := 0
+	for i := range tokens {
+		literalLen := streams[literalLenStream][i]
+		if streams[flagsStream][i/8]&(1<<(7-i%8)) == 0 {
+			tokens[i] = Token{Literal: literalLen}
+			continue
+		}
+		offset := int(streams[offsetHighStream][matchIdx])<<8 | int(streams[offsetLowStream][matchIdx])
+		tokens[i] = Token{Offset: offset + 1, Length: int(literalLen) + MinMatchLen}
+		matchIdx++
+	}
+	uncompressedData, err := Detokenize(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if uncompressedData == nil {
+		uncompressedData = []byte{}
+	}
+	return &uncompressedData, nil
+}
@@ -0,0 +1,158 @@
+package lz77
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LZ77 replaces a run of bytes that already showed up in the last windowSize bytes with a match: how far back it
+// starts (offset) and how long it is (length). Everything that isn't part of a match is left as a literal. Matches are
+// found with hash chains, every position is hashed on its first MinMatchLen bytes and linked to the last position
+// that had the same hash, so the only places that get compared are the ones that could start a match.
+//
+// The tokens are written out LZSS style, with a flag bit for every token saying if it's a literal or a match instead of
+// sending every literal as a match of length 0, see EncodeLZSS. Compress splits the same tokens up into streams that
+// each get their own canonical huffman code, see compress.go.
+
+const (
+	MinMatchLen = 3
+	// MaxMatchLen is as long as a match can get with its length-MinMatchLen stored in a byte
+	MaxMatchLen = MinMatchLen + 0xFF
+	// MaxWindowSize is as far back as an offset-1 stored in 16 bits can go
+	MaxWindowSize     = 1 << 16
+	DefaultWindowSize = 32 << 10
+
+	hashBits = 15
+	// maxChainLength is how many earlier positions with the same hash get compared before taking the best so far.
+	// Longer chains find longer matches on repetitive data but take longer
+	maxChainLength = 128
+	// a match this long is good enough to take without checking if the next position has a longer one
+	lazyMatchLen = 32
+)
+
+var ErrCorruptData = errors.New("lz77: corrupt data")
+
+// Token is a literal byte when Length is 0, otherwise it is a copy of the Length bytes that start Offset bytes back
+type Token struct {
+	Literal byte
+	Offset  int
+	Length  int
+}
+
+func (t Token) IsMatch() bool {
+	return t.Length > 0
+}
+
+// Tokenize turns data into literals and matches that reach at most windowSize bytes back
+func Tokenize(data []byte, windowSize int) ([]Token, error) {
+	if windowSize <= 0 || windowSize > MaxWindowSize {
+		return nil, fmt.Errorf("lz77: window size %v is not between 1 and %v", windowSize, MaxWindowSize)
+	}
+	mf := newMatchFinder(data, windowSize)
+	var tokens []Token
+	for pos := 0; pos < len(data); {
+		mf.insertUpTo(pos)
+		length, offset := mf.findMatch(pos)
+		// a literal now can be worth it when the next position starts a longer match
+		if length >= MinMatchLen && length < lazyMatchLen && pos+1 < len(data) {
+			mf.insertUpTo(pos + 1)
+			if nextLength, _ := mf.findMatch(pos + 1); nextLength > length {
+				length = 0
+			}
+		}
+		if length < MinMatchLen {
+			tokens = append(tokens, Token{Literal: data[pos]})
+			pos++
+			continue
+		}
+		tokens = append(tokens, Token{Offset: offset, Length: length})
+		pos += length
+	}
+	return tokens, nil
+}
+
+// Detokenize rebuilds the data from its tokens. A match can overlap the bytes it is copying, an offset of 1 with a
+// length of 10 repeats the last byte 10 times
+func Detokenize(tokens []Token) ([]byte, error) {
+	var data []byte
+	for i, token := range tokens {
+		if !token.IsMatch() {
+			data = append(data, token.Literal)
+			continue
+		}
+		if token.Offset <= 0 || token.Offset > len(data) || token.Length < 0 {
+			return nil, fmt.Errorf("%w: token %v goes %v bytes back with only %v bytes before it", ErrCorruptData, i, token.Offset, len(data))
+		}
+		start := len(data) - token.Offset
+		for j := 0; j < token.Length; j++ {
+			data = append(data, data[start+j])
+		}
+	}
+	return data, nil
+}
+
+// Helpers
+
+type matchFinder struct {
+	data       []byte
+	windowSize int
+	// head is the last position+1 with every hash and prev[pos % windowSize] the position+1 before pos with the same
+	// hash as pos, 0 is the end of the chain. A slot in prev only gets reused once pos is out of the window anyway
+	head     []int32
+	prev     []int32
+	inserted int
+}
+
+func newMatchFinder(data []byte, windowSize int) *matchFinder {
+	return &matchFinder{data: data, windowSize: windowSize, head: make([]int32, 1<<hashBits), prev: make([]int32, windowSize)}
+}
+
+func (mf *matchFinder) hash(pos int) uint32 {
+	value := uint32(mf.data[pos])<<16 | uint32(mf.data[pos+1])<<8 | uint32(mf.data[pos+2])
+	return (value * 2654435761) >> (32 - hashBits)
+}
+
+// insertUpTo adds every position before pos to the hash chains
+func (mf *matchFinder) insertUpTo(pos int) {
+	for ; mf.inserted < pos; mf.inserted++ {
+		if mf.inserted+MinMatchLen > len(mf.data) {
+			continue
+		}
+		h := mf.hash(mf.inserted)
+		mf.prev[mf.inserted%mf.windowSize] = mf.head[h]
+		mf.head[h] = int32(mf.inserted + 1)
+	}
+}
+
+// findMatch returns the longest match for pos among the positions already inserted, or a length of 0
+func (mf *matchFinder) findMatch(pos int) (length, offset int) {
+	maxLen := min(MaxMatchLen, len(mf.data)-pos)
+	if maxLen < MinMatchLen {
+		return 0, 0
+	}
+	candidate := int(mf.head[mf.hash(pos)])
+	for chain := 0; candidate > 0 && chain < maxChainLength; chain++ {
+		candidatePos := candidate - 1
+		if pos-candidatePos > mf.windowSize {
+			break
+		}
+		// a longer match has to at least get the byte past the best one right, check that one first
+		if mf.data[candidatePos+length] == mf.data[pos+length] || length == 0 {
+			matchLen := 0
+			for matchLen < maxLen && mf.data[candidatePos+matchLen] == mf.data[pos+matchLen] {
+				matchLen++
+			}
+			if matchLen > length {
+				length, offset = matchLen, pos-candidatePos
+				if length == maxLen {
+					break
+				}
+			}
+		}
+		candidate = int(mf.prev[candidatePos%mf.windowSize])
+	}
+	if length < MinMatchLen {
+		return 0, 0
+	}
+	return length, offset
+}
@@ -0,0 +1,186 @@
+package lz77
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ElwinCabrera/go-compression/lossless/huffman"
+	testingutils "github.com/ElwinCabrera/go-compression/testing_utils"
+)
+
+func getJSONTestData(numRecords int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	names := []string{"alice", "bob", "carol", "dave", "erin", "frank"}
+	var buf bytes.Buffer
+	buf.WriteString("[\n")
+	for i := 0; i < numRecords; i++ {
+		fmt.Fprintf(&buf, `  {"id": %d, "user": {"name": "%s", "active": %t}, "tags": ["api", "v%d"], "latency_ms": %d}`,
+			i, names[rnd.Intn(len(names))], rnd.Intn(2) == 0, 1+rnd.Intn(3), rnd.Intn(500))
+		buf.WriteString(",\n")
+	}
+	buf.WriteString("]\n")
+	return buf.Bytes()
+}
+
+func getLogTestData(numLines int) []byte {
+	rnd := rand.New(rand.NewSource(2))
+	levels := []string{"INFO", "INFO", "INFO", "WARN", "ERROR"}
+	paths := []string{"/api/users", "/api/orders", "/healthz", "/api/items"}
+	var buf bytes.Buffer
+	for i := 0; i < numLines; i++ {
+		fmt.Fprintf(&buf, "2024-03-%02dT%02d:%02d:%02dZ %-5s request handled method=GET path=%s/%d status=%d duration=%dms\n",
+			1+i/86400, i/3600%24, i/60%60, i%60, levels[rnd.Intn(len(levels))], paths[rnd.Intn(len(paths))],
+			rnd.Intn(1000), 200+100*rnd.Intn(4), rnd.Intn(900))
+	}
+	return buf.Bytes()
+}
+
+func getTestData() [][]byte {
+	return [][]byte{
+		{},
+		{'A'},
+		[]byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"),
+		[]byte("A_DEAD_DAD_CEDED_A_BAD_BABE_A_BEADED_ABACA_BED"),
+		bytes.Repeat([]byte("abcdefgh"), 10000),
+		bytes.Repeat([]byte{0}, 100000),
+		getJSONTestData(2000),
+		getLogTestData(2000),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(50000, 256),
+		testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(50000, 4),
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, _ := Tokenize([]byte("abcabcabcabcx"), DefaultWindowSize)
+	expected := []Token{{Literal: 'a'}, {Literal: 'b'}, {Literal: 'c'}, {Offset: 3, Length: 9}, {Literal: 'x'}}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, tokens)
+	}
+	for i := range tokens {
+		if tokens[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, tokens)
+		}
+	}
+
+	for _, windowSize := range []int{0, -1, MaxWindowSize + 1} {
+		if _, err := Tokenize([]byte("abc"), windowSize); err == nil {
+			t.Fatalf("expected an error for a window size of %v", windowSize)
+		}
+	}
+}
+
+func TestTokenizeWindowSize(t *testing.T) {
+	// the same 1000 random bytes twice, 5000 bytes apart
+	block := testingutils.GetByteArrayOfSizeXOfRandomBytesWithMaxRandomByteValue(1000, 256)
+	data := append(append(bytes.Clone(block), make([]byte, 4000)...), block...)
+	for _, windowSize := range []int{1, 100, 4999, 5000, MaxWindowSize} {
+		tokens, err := Tokenize(data, windowSize)
+		if err != nil {
+			t.Fatalf("window size %v: %v", windowSize, err)
+		}
+		foundFarMatch := false
+		for _, token := range tokens {
+			if token.IsMatch() && (token.Offset > windowSize || token.Length < MinMatchLen || token.Length > MaxMatchLen) {
+				t.Fatalf("window size %v: token %+v is not valid", windowSize, token)
+			}
+			foundFarMatch = foundFarMatch || token.Offset == 5000
+		}
+		if foundFarMatch != (windowSize >= 5000) {
+			t.Fatalf("window size %v: found a match 5000 bytes back %v", windowSize, foundFarMatch)
+		}
+		if detokenized, err := Detokenize(tokens); err != nil || !bytes.Equal(data, detokenized) {
+			t.Fatalf("window size %v does not round trip (err %v)", windowSize, err)
+		}
+	}
+}
+
+func TestLZSS(t *testing.T) {
+	for i, data := range getTestData() {
+		tokens, _ := Tokenize(data, DefaultWindowSize)
+		encodedData := EncodeLZSS(tokens)
+		decodedTokens, err := DecodeLZSS(encodedData)
+		if err != nil {
+			t.Fatalf("dataset #%v: %v", i, err)
+		}
+		decodedData, err := Detokenize(decodedTokens)
+		if err != nil || !bytes.Equal(data, decodedData) {
+			t.Fatalf("dataset #%v does not round trip (err %v)", i, err)
+		}
+	}
+
+	encodedData := EncodeLZSS([]Token{{Literal: 'a'}, {Offset: 1, Length: 10}})
+	if expected := []byte{0b0100_0000, 'a', 10 - MinMatchLen, 0, 0}; !bytes.Equal(expected, encodedData) {
+		t.Fatalf("expected % X but got % X", expected, encodedData)
+	}
+	for n := 1; n < len(encodedData); n++ {
+		if _, err := DecodeLZSS(encodedData[:n]); n != 2 && !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("expected ErrCorruptData for data cut down to %v bytes but got %v", n, err)
+		}
+	}
+}
+
+func TestCompressDecompress(t *testing.T) {
+	for i, data := range getTestData() {
+		compressedData, _ := Compress(&data)
+		decompressedData, err := Decompress(&compressedData)
+		if err != nil || !bytes.Equal(data, *decompressedData) {
+			t.Fatalf("dataset #%v does not round trip (err %v)", i, err)
+		}
+	}
+}
+
+// repeated phrases are what huffman alone can't do anything about
+func TestCompressRatio(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"json", getJSONTestData(2000)},
+		{"logs", getLogTestData(2000)},
+	} {
+		lz77Data, _ := Compress(&test.data)
+		tokens, _ := Tokenize(test.data, DefaultWindowSize)
+		lzssData := EncodeLZSS(tokens)
+		huffmanData, _ := huffman.CompressCanonical(&test.data)
+		t.Logf("%v: %v bytes, lz77+huffman %v bytes, lzss %v bytes, huffman %v bytes", test.name, len(test.data),
+			len(lz77Data), len(lzssData), len(huffmanData))
+		if float64(len(lz77Data)) >= 0.5*float64(len(huffmanData)) || len(lz77Data) >= len(lzssData) {
+			t.Errorf("%v: lz77 compressed %v bytes to %v, huffman alone gives %v and plain lzss %v", test.name,
+				len(test.data), len(lz77Data), len(huffmanData), len(lzssData))
+		}
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	data := getLogTestData(100)
+	compressedData, _ := Compress(&data)
+	for n := 0; n < len(compressedData); n++ {
+		truncated := compressedData[:n]
+		if _, err := Decompress(&truncated); !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("expected ErrCorruptData for data cut down to %v bytes but got %v", n, err)
+		}
+	}
+
+	// a match that goes back further than the data
+	if _, err := Detokenize([]Token{{Literal: 'a'}, {Offset: 2, Length: 3}}); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for an offset past the start but got %v", err)
+	}
+	numTokens, n := binary.Uvarint(compressedData)
+	tooManyTokens := append(binary.AppendUvarint(nil, numTokens+8), compressedData[n:]...)
+	if _, err := Decompress(&tooManyTokens); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for the wrong token count but got %v", err)
+	}
+	// (numTokens+7)/8 wraps around to 0 flag bytes, which four empty streams would match
+	hugeTokenCount := binary.AppendUvarint(nil, math.MaxUint64)
+	for range numStreams {
+		hugeTokenCount = append(hugeTokenCount, 1, 0x00)
+	}
+	if _, err := Decompress(&hugeTokenCount); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a huge token count but got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package lz77
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// LZSS layout, the tokens go in groups of 8 behind a flag byte:
+//	<flags><token>...<token> <flags><token>... ...
+//	1 byte  up to 8 tokens
+// Bit 7-i of the flags is 1 when token i of the group is a match. A literal is the byte itself, a match is
+// <length-MinMatchLen><offset-1> in 1 byte and 2 bytes (big endian). The data ends after the last token, any flags left
+// over in the last group are 0.
+
+func EncodeLZSS(tokens []Token) []byte {
+	var encodedData []byte
+	flagsIdx := 0
+	for i, token := range tokens {
+		if i%8 == 0 {
+			flagsIdx = len(encodedData)
+			encodedData = append(encodedData, 0)
+		}
+		if !token.IsMatch() {
+			encodedData = append(encodedData, token.Literal)
+			continue
+		}
+		encodedData[flagsIdx] |= 1 << (7 - i%8)
+		encodedData = append(encodedData, byte(token.Length-MinMatchLen))
+		encodedData = binary.BigEndian.AppendUint16(encodedData, uint16(token.Offset-1))
+	}
+	return encodedData
+}
+
+func DecodeLZSS(encodedData []byte) ([]Token, error) {
+	var tokens []Token
+	var flags byte
+	for i, idx := 0, 0; idx < len(encodedData); i++ {
+		if i%8 == 0 {
+			flags = encodedData[idx]
+			idx++
+			// the last group can't be just a flag byte
+			if idx == len(encodedData) {
+				return nil, fmt.Errorf("%w: flags without any tokens at the end", ErrCorruptData)
+			}
+		}
+		if flags&(1<<(7-i%8)) == 0 {
+			tokens = append(tokens, Token{Literal: encodedData[idx]})
+			idx++
+			continue
+		}
+		if len(encodedData)-idx < 3 {
+			return nil, fmt.Errorf("%w: match %v is cut off", ErrCorruptData, i)
+		}
+		length := int(encodedData[idx]) + MinMatchLen
+		offset := int(binary.BigEndian.Uint16(encodedData[idx+1:])) + 1
+		tokens = append(tokens, Token{Offset: offset, Length: length})
+		idx += 3
+	}
+	return tokens, nil
+}
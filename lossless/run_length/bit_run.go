@@ -0,0 +1,182 @@
+package run_length
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Bit-run encoding is for data where the bits themselves come in long runs, like bitmaps and masks, which RunLengthEncode
+// can't do anything with since the bytes inside a run of 1s next to a run of 0s are all different. The data is read as
+// one long string of bits (most significant bit of every byte first) and only the lengths of the runs are written, the
+// runs always alternate between 0s and 1s so the first bit is all it takes to know what every run is.
+//
+// Every run length is written with an Elias-gamma code: the length n >= 1 in binary preceded by one 0 for every bit
+// after its leading 1, so a run of 1 costs 1 bit, a run of 2 or 3 costs 3 bits and a run of 2^k costs 2k+1 bits.
+//
+// Encoded data layout:
+//	<num_bytes><first bit><gamma coded run lengths...><0s to fill the last byte>
+//	  uvarint     1 bit
+// There is nothing after num_bytes when it is 0.
+
+// maxGammaBits is as long as the number of zeros in front of a run length can get, a run can't be longer than all the
+// bits in a slice
+const maxGammaBits = 64
+
+// DefaultMaxDecodedLen is the most BitRunDecode and ZeroRunDecode will decode. A run of n costs about 2*log2(n) bits
+// either way, so a few bytes of made up input can ask for any amount of output and the only thing that can stop it is
+// a limit on the output. Use the WithLimit versions when the real length is known
+const DefaultMaxDecodedLen = 1 << 30
+
+func BitRunEncode(data []byte) []byte {
+	encodedData := binary.AppendUvarint(nil, uint64(len(data)))
+	if len(data) == 0 {
+		return encodedData
+	}
+	bw := &bitWriter{out: encodedData}
+	bit := dataBit(data, 0)
+	bw.writeBits(uint64(bit), 1)
+
+	numBits := uint64(len(data)) * 8
+	for i := uint64(0); i < numBits; {
+		runLength := uint64(1)
+		for i+runLength < numBits && dataBit(data, i+runLength) == bit {
+			runLength++
+		}
+		bw.writeGamma(runLength)
+		i += runLength
+		bit ^= 1
+	}
+	return bw.finish()
+}
+
+func BitRunDecode(encodedData []byte) ([]byte, error) {
+	return BitRunDecodeWithLimit(encodedData, DefaultMaxDecodedLen)
+}
+
+// BitRunDecodeWithLimit fails with ErrCorruptData instead of decoding more than maxLen bytes
+func BitRunDecodeWithLimit(encodedData []byte, maxLen uint64) ([]byte, error) {
+	numBytes, n := binary.Uvarint(encodedData)
+	if n <= 0 || numBytes > math.MaxUint64/8 {
+		return nil, fmt.Errorf("%w: bad byte count", ErrCorruptData)
+	}
+	if numBytes > maxLen {
+		return nil, fmt.Errorf("%w: %v bytes is more than the limit of %v", ErrCorruptData, numBytes, maxLen)
+	}
+	if numBytes == 0 {
+		return []byte{}, nil
+	}
+
+	br := &bitReader{in: encodedData[n:]}
+	bit, ok := br.readBits(1)
+	if !ok {
+		return nil, fmt.Errorf("%w: data ends before the first bit", ErrCorruptData)
+	}
+	// the data only grows as the runs come in, so a made up byte count with too few runs behind it fails before it gets
+	// all allocated. One long run still allocates all of it at once, which is why the byte count has to be under maxLen
+	var data []byte
+	numBits := numBytes * 8
+	for i := uint64(0); i < numBits; {
+		runLength, ok := br.readGamma()
+		if !ok || runLength > numBits-i {
+			return nil, fmt.Errorf("%w: bad run length at bit %v", ErrCorruptData, i)
+		}
+		if runEnd := (i + runLength + 7) / 8; runEnd > uint64(len(data)) {
+			data = append(data, make([]byte, runEnd-uint64(len(data)))...)
+		}
+		if bit == 1 {
+			setDataBits(data, i, runLength)
+		}
+		i += runLength
+		bit ^= 1
+	}
+	return data, nil
+}
+
+// Helpers
+
+func dataBit(data []byte, bitIdx uint64) byte {
+	return data[bitIdx/8] >> (7 - bitIdx%8) & 1
+}
+
+// setDataBits sets runLength bits to 1 starting at bitIdx
+func setDataBits(data []byte, bitIdx, runLength uint64) {
+	for ; runLength > 0 && bitIdx%8 != 0; runLength-- {
+		data[bitIdx/8] |= 1 << (7 - bitIdx%8)
+		bitIdx++
+	}
+	for ; runLength >= 8; runLength -= 8 {
+		data[bitIdx/8] = 0xFF
+		bitIdx += 8
+	}
+	for ; runLength > 0; runLength-- {
+		data[bitIdx/8] |= 1 << (7 - bitIdx%8)
+		bitIdx++
+	}
+}
+
+// bitWriter appends bits to out most significant bit first
+type bitWriter struct {
+	out     []byte
+	curByte byte
+	numBits uint
+}
+
+func (bw *bitWriter) writeBits(value uint64, numBits uint) {
+	for i := int(numBits) - 1; i >= 0; i-- {
+		bw.curByte = bw.curByte<<1 | byte(value>>i&1)
+		if bw.numBits++; bw.numBits == 8 {
+			bw.out = append(bw.out, bw.curByte)
+			bw.curByte, bw.numBits = 0, 0
+		}
+	}
+}
+
+func (bw *bitWriter) writeGamma(value uint64) {
+	numBits := uint(bits.Len64(value))
+	bw.writeBits(0, numBits-1)
+	bw.writeBits(value, numBits)
+}
+
+// finish pads the last byte with 0s and returns everything written
+func (bw *bitWriter) finish() []byte {
+	if bw.numBits > 0 {
+		bw.out = append(bw.out, bw.curByte<<(8-bw.numBits))
+		bw.curByte, bw.numBits = 0, 0
+	}
+	return bw.out
+}
+
+type bitReader struct {
+	in     []byte
+	bitIdx uint64
+}
+
+func (br *bitReader) readBits(numBits uint) (uint64, bool) {
+	if uint64(len(br.in))*8-br.bitIdx < uint64(numBits) {
+		return 0, false
+	}
+	value := uint64(0)
+	for i := uint(0); i < numBits; i++ {
+		value = value<<1 | uint64(dataBit(br.in, br.bitIdx))
+		br.bitIdx++
+	}
+	return value, true
+}
+
+func (br *bitReader) readGamma() (uint64, bool) {
+	numZeros := uint(0)
+	for {
+		bit, ok := br.readBits(1)
+		if !ok || numZeros >= maxGammaBits {
+			return 0, false
+		}
+		if bit == 1 {
+			break
+		}
+		numZeros++
+	}
+	rest, ok := br.readBits(numZeros)
+	return 1<<numZeros | rest, ok
+}
@@ -0,0 +1,90 @@
+package run_length
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func getBitRunTestData() [][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	// a mask that is mostly 0s with the odd short run of 1s, like a bitmap of which blocks of a file are in use
+	sparseMask := make([]byte, 1<<16)
+	for bitIdx := 0; bitIdx < len(sparseMask)*8; bitIdx += 500 + rnd.Intn(1000) {
+		for i := 0; i < 1+rnd.Intn(40) && bitIdx+i < len(sparseMask)*8; i++ {
+			sparseMask[(bitIdx+i)/8] |= 1 << (7 - (bitIdx+i)%8)
+		}
+	}
+	randomBytes := make([]byte, 1000)
+	rnd.Read(randomBytes)
+	return [][]byte{
+		{},
+		{0x00},
+		{0xFF},
+		{0x80},
+		{0x01},
+		{0xAA, 0x55},
+		{0x0F, 0xF0, 0x0F},
+		bytes.Repeat([]byte{0x00}, 100000),
+		bytes.Repeat([]byte{0xFF}, 100000),
+		sparseMask,
+		randomBytes,
+	}
+}
+
+func TestBitRunEncode(t *testing.T) {
+	for i, data := range getBitRunTestData() {
+		compressedData := BitRunEncode(data)
+		uncompressedData, err := BitRunDecode(compressedData)
+		if err != nil || !bytes.Equal(data, uncompressedData) {
+			t.Fatalf("dataset #%v does not round trip (err %v)", i, err)
+		}
+		t.Logf("dataset #%v: %v bytes to %v bytes", i, len(data), len(compressedData))
+	}
+
+	sparseMask := getBitRunTestData()[9]
+	if compressedData := BitRunEncode(sparseMask); len(compressedData) > len(sparseMask)/10 {
+		t.Fatalf("sparse mask of %v bytes only went down to %v bytes", len(sparseMask), len(compressedData))
+	}
+	// num_bytes, first bit 0 then a single run of 800000 bits: 19 zeros and 20 bits, 5 bytes in all
+	if compressedData := BitRunEncode(bytes.Repeat([]byte{0x00}, 100000)); len(compressedData) != 3+5 {
+		t.Fatalf("100000 zero bytes encoded to %v bytes", len(compressedData))
+	}
+}
+
+func TestBitRunDecodeCorrupt(t *testing.T) {
+	data := getBitRunTestData()[9]
+	compressedData := BitRunEncode(data)
+	for _, n := range []int{0, 1, 3, len(compressedData) / 2, len(compressedData) - 1} {
+		if _, err := BitRunDecode(compressedData[:n]); !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("expected ErrCorruptData for data cut down to %v bytes but got %v", n, err)
+		}
+	}
+	// a run of 9 bits in 1 byte of data
+	if _, err := BitRunDecode([]byte{0x01, 0b0_000_1_001}); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a run past the end but got %v", err)
+	}
+	// a huge byte count with one short run can't allocate it all
+	if _, err := BitRunDecode([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F, 0b1_1_000000}); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a huge byte count but got %v", err)
+	}
+	// a huge byte count and one run that covers all of it is only 22 bytes but would be 2^50 bytes decoded
+	hugeRun := binary.AppendUvarint(nil, 1<<50)
+	bw := &bitWriter{out: hugeRun}
+	bw.writeBits(0, 1)
+	bw.writeGamma(8 << 50)
+	hugeRun = bw.finish()
+	if _, err := BitRunDecode(hugeRun); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a huge byte count with one huge run but got %v", err)
+	}
+
+	compressedData = BitRunEncode(data)
+	if _, err := BitRunDecodeWithLimit(compressedData, uint64(len(data)-1)); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData with a limit below the real length but got %v", err)
+	}
+	if uncompressedData, err := BitRunDecodeWithLimit(compressedData, uint64(len(data))); err != nil || !bytes.Equal(data, uncompressedData) {
+		t.Fatalf("round trip with the limit at the real length failed: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package run_length
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// PackBits (the run-length scheme from the Macintosh and TIFF) puts a header byte in front of every run that says what
+// kind of run it is, so bytes that don't repeat are copied as they are instead of each getting a count of 1.
+//
+// Encoded data layout, header byte n read as an int8:
+//	0 to 127:    n+1 literal bytes follow
+//	-1 to -127:  the one byte that follows is repeated 1-n times (2 to 128)
+//	-128:        nothing, skipped
+//
+// A literal run costs 1 byte on top of up to 128 bytes and a repeat run never costs more than the bytes it stands for,
+// so the output is never more than len(data) + ceil(len(data)/128) bytes.
+
+const (
+	maxPackBitsRun = 128
+	// a run of 2 as a repeat costs as much as it does as literals and it would split the literal run up, it is only
+	// worth it from 3 on
+	minPackBitsRepeat = 3
+	packBitsNoOp      = 0x80
+)
+
+var ErrCorruptData = errors.New("run_length: corrupt data")
+
+func PackBitsEncode(data []byte) []byte {
+	var buffer bytes.Buffer
+	literalStart := 0
+	for i := 0; i < len(data); {
+		runLength := 1
+		for runLength < maxPackBitsRun && i+runLength < len(data) && data[i+runLength] == data[i] {
+			runLength++
+		}
+		if runLength < minPackBitsRepeat {
+			i += runLength
+			continue
+		}
+		writePackBitsLiterals(&buffer, data[literalStart:i])
+		buffer.WriteByte(byte(1 - runLength))
+		buffer.WriteByte(data[i])
+		i += runLength
+		literalStart = i
+	}
+	writePackBitsLiterals(&buffer, data[literalStart:])
+	return buffer.Bytes()
+}
+
+func PackBitsDecode(encodedData []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	for i := 0; i < len(encodedData); {
+		header := encodedData[i]
+		i++
+		switch {
+		case header == packBitsNoOp:
+		case header < packBitsNoOp:
+			literalLen := int(header) + 1
+			if len(encodedData)-i < literalLen {
+				return nil, fmt.Errorf("%w: literal run of %v bytes with only %v left", ErrCorruptData, literalLen, len(encodedData)-i)
+			}
+			buffer.Write(encodedData[i : i+literalLen])
+			i += literalLen
+		default:
+			if i == len(encodedData) {
+				return nil, fmt.Errorf("%w: repeat run is missing its byte", ErrCorruptData)
+			}
+			runLength := 1 - int(int8(header))
+			for j := 0; j < runLength; j++ {
+				buffer.WriteByte(encodedData[i])
+			}
+			i++
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// Helpers
+
+// writePackBitsLiterals writes literals out in runs of up to maxPackBitsRun
+func writePackBitsLiterals(buffer *bytes.Buffer, literals []byte) {
+	for len(literals) > 0 {
+		literalLen := min(len(literals), maxPackBitsRun)
+		buffer.WriteByte(byte(literalLen - 1))
+		buffer.Write(literals[:literalLen])
+		literals = literals[literalLen:]
+	}
+}
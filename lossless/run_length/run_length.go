@@ -1,34 +1,31 @@
 package run_length
 
-import "bytes"
+import (
+	"bytes"
+	"fmt"
+)
 
+// RunLengthEncode writes every run as a (count, byte) pair, runs longer than 255 are split up into more pairs. This is
+// the simple mode, data without runs comes out twice as big, see PackBitsEncode for something that can't blow up like
+// that
 func RunLengthEncode(data []byte) []byte {
 	var buffer bytes.Buffer
-	runLength := uint8(1)
-	for i := 0; i < len(data); i++ {
-
-		if i+1 < len(data) && data[i] == data[i+1] {
+	for i := 0; i < len(data); {
+		runLength := 1
+		for runLength < 0xFF && i+runLength < len(data) && data[i+runLength] == data[i] {
 			runLength++
-			if runLength == 0xFF {
-				buffer.WriteByte(0xFF)
-				buffer.WriteByte(runLength)
-				runLength = 1
-			}
-		} else {
-			buffer.WriteByte(runLength)
-			if i == 0 {
-				buffer.WriteByte(data[0])
-			} else {
-				buffer.WriteByte(data[i])
-			}
-			runLength = 1
 		}
+		buffer.WriteByte(byte(runLength))
+		buffer.WriteByte(data[i])
+		i += runLength
 	}
 	return buffer.Bytes()
 }
 
+// RunLengthDecode returns nil when encodedData isn't made up of whole (count, byte) pairs, NewReader says what's wrong
+// with it instead
 func RunLengthDecode(encodedData []byte) []byte {
-	if len(encodedData) < 2 {
+	if len(encodedData) < 2 || len(encodedData)%2 != 0 {
 		return nil
 	}
 	var buffer bytes.Buffer
@@ -39,3 +36,40 @@ func RunLengthDecode(encodedData []byte) []byte {
 	}
 	return buffer.Bytes()
 }
+
+// RunLengthEncodeWords is RunLengthEncode for fixed width symbols, every run of the same wordSize bytes is written as a
+// count byte followed by the word. len(data) has to be a multiple of wordSize
+func RunLengthEncodeWords(data []byte, wordSize int) ([]byte, error) {
+	if wordSize <= 0 || len(data)%wordSize != 0 {
+		return nil, fmt.Errorf("run_length: %v bytes can't be split into words of %v bytes", len(data), wordSize)
+	}
+	var buffer bytes.Buffer
+	for i := 0; i < len(data); {
+		word := data[i : i+wordSize]
+		runLength := 1
+		for runLength < 0xFF && i+(runLength+1)*wordSize <= len(data) && bytes.Equal(word, data[i+runLength*wordSize:i+(runLength+1)*wordSize]) {
+			runLength++
+		}
+		buffer.WriteByte(byte(runLength))
+		buffer.Write(word)
+		i += runLength * wordSize
+	}
+	return buffer.Bytes(), nil
+}
+
+func RunLengthDecodeWords(encodedData []byte, wordSize int) ([]byte, error) {
+	if wordSize <= 0 {
+		return nil, fmt.Errorf("run_length: word size %v is not valid", wordSize)
+	}
+	if len(encodedData)%(1+wordSize) != 0 {
+		return nil, fmt.Errorf("%w: %v bytes is not a whole number of (count, word) pairs", ErrCorruptData, len(encodedData))
+	}
+	var buffer bytes.Buffer
+	for i := 0; i < len(encodedData); i += 1 + wordSize {
+		word := encodedData[i+1 : i+1+wordSize]
+		for j := uint8(0); j < encodedData[i]; j++ {
+			buffer.Write(word)
+		}
+	}
+	return buffer.Bytes(), nil
+}
@@ -1,7 +1,10 @@
 package run_length
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -18,6 +21,126 @@ func TestRunLengthEncode(t *testing.T) {
 	}
 }
 
+func TestRunLengthEncodeLongRuns(t *testing.T) {
+	for _, runLength := range []int{254, 255, 256, 510, 511, 1000} {
+		data := append(bytes.Repeat([]byte{'W'}, runLength), 'B')
+		compressedData := RunLengthEncode(data)
+		if uncompressedData := RunLengthDecode(compressedData); !bytes.Equal(data, uncompressedData) {
+			t.Fatalf("run of %v does not round trip, got %v bytes back", runLength, len(uncompressedData))
+		}
+		if expectedLen := 2 * ((runLength+254)/255 + 1); len(compressedData) != expectedLen {
+			t.Fatalf("run of %v encoded to %v bytes, expected %v", runLength, len(compressedData), expectedLen)
+		}
+	}
+}
+
+func TestPackBits(t *testing.T) {
+	// the example from Apple's PackBits technote
+	data := []byte{0xAA, 0xAA, 0xAA, 0x80, 0x00, 0x2A, 0xAA, 0xAA, 0xAA, 0xAA, 0x80, 0x00, 0x2A, 0x22,
+		0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}
+	expected := []byte{0xFE, 0xAA, 0x02, 0x80, 0x00, 0x2A, 0xFD, 0xAA, 0x03, 0x80, 0x00, 0x2A, 0x22, 0xF7, 0xAA}
+	if compressedData := PackBitsEncode(data); !bytes.Equal(expected, compressedData) {
+		t.Fatalf("expected % X but got % X", expected, compressedData)
+	}
+
+	for i, data := range getPackBitsTestData() {
+		compressedData := PackBitsEncode(data)
+		uncompressedData, err := PackBitsDecode(compressedData)
+		if err != nil || !bytes.Equal(data, uncompressedData) {
+			t.Fatalf("dataset #%v does not round trip (err %v)", i, err)
+		}
+		if maxLen := len(data) + (len(data)+maxPackBitsRun-1)/maxPackBitsRun; len(compressedData) > maxLen {
+			t.Fatalf("dataset #%v of %v bytes encoded to %v bytes, expected at most %v", i, len(data), len(compressedData), maxLen)
+		}
+	}
+}
+
+func TestPackBitsCorrupt(t *testing.T) {
+	data := []byte("WWWWWWWWWWWWBWWWWWWWWWWWWBBBWWWWWWWWWWWWWWWWWWWWWWWWBWWWWWWWWWWWWWW")
+	compressedData := PackBitsEncode(data)
+	for n := 0; n < len(compressedData); n++ {
+		// cutting the data right after a run leaves valid data that is just shorter
+		if uncompressedData, err := PackBitsDecode(compressedData[:n]); err == nil && !bytes.HasPrefix(data, uncompressedData) {
+			t.Fatalf("data cut down to %v bytes decoded to something else", n)
+		}
+	}
+	if _, err := PackBitsDecode([]byte{0x05, 'A', 'B'}); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a short literal run but got %v", err)
+	}
+	if _, err := PackBitsDecode([]byte{0xFE}); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a repeat run without its byte but got %v", err)
+	}
+	if uncompressedData, err := PackBitsDecode([]byte{packBitsNoOp, 0x00, 'A'}); err != nil || string(uncompressedData) != "A" {
+		t.Fatalf("expected the no-op header to be skipped, got %q (err %v)", uncompressedData, err)
+	}
+}
+
+func TestRunLengthEncodeWords(t *testing.T) {
+	// a sensor that reads the same 16 or 32 bit value for a while before it changes
+	rnd := rand.New(rand.NewSource(1))
+	for _, wordSize := range []int{1, 2, 3, 4, 8} {
+		var data []byte
+		for len(data) < 100000 {
+			word := make([]byte, wordSize)
+			rnd.Read(word)
+			data = append(data, bytes.Repeat(word, 1+rnd.Intn(600))...)
+		}
+		data = data[:len(data)/wordSize*wordSize]
+
+		compressedData, err := RunLengthEncodeWords(data, wordSize)
+		if err != nil {
+			t.Fatalf("word size %v: %v", wordSize, err)
+		}
+		uncompressedData, err := RunLengthDecodeWords(compressedData, wordSize)
+		if err != nil || !bytes.Equal(data, uncompressedData) {
+			t.Fatalf("word size %v does not round trip (err %v)", wordSize, err)
+		}
+		if len(compressedData) > len(data)/50 {
+			t.Fatalf("word size %v: %v bytes only went down to %v bytes", wordSize, len(data), len(compressedData))
+		}
+		// RunLengthEncode sees the same bytes as words of 1 byte
+		if wordSize == 1 && !bytes.Equal(compressedData, RunLengthEncode(data)) {
+			t.Fatalf("words of 1 byte don't encode the same as RunLengthEncode")
+		}
+	}
+
+	if _, err := RunLengthEncodeWords([]byte{1, 2, 3}, 2); err == nil {
+		t.Fatalf("expected an error for data that isn't a whole number of words")
+	}
+	if _, err := RunLengthEncodeWords([]byte{1, 2}, 0); err == nil {
+		t.Fatalf("expected an error for a word size of 0")
+	}
+	if _, err := RunLengthDecodeWords([]byte{2, 'A', 'B', 1, 'C'}, 2); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a truncated pair but got %v", err)
+	}
+}
+
+func getPackBitsTestData() [][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	noRuns := make([]byte, 10000)
+	for i := range noRuns {
+		noRuns[i] = byte(i)
+	}
+	// runs of 1 to 4 bytes, right around where a repeat run starts being worth it
+	shortRuns := make([]byte, 0, 10000)
+	for len(shortRuns) < 10000 {
+		shortRuns = append(shortRuns, bytes.Repeat([]byte{byte(rnd.Intn(256))}, 1+rnd.Intn(4))...)
+	}
+	randomBytes := make([]byte, 10000)
+	rnd.Read(randomBytes)
+	return [][]byte{
+		{},
+		{'A'},
+		{'A', 'A'},
+		{'A', 'A', 'A'},
+		[]byte("WWWWWWWWWWWWBWWWWWWWWWWWWBBBWWWWWWWWWWWWWWWWWWWWWWWWBWWWWWWWWWWWWWW"),
+		bytes.Repeat([]byte{0}, 1000),
+		noRuns,
+		shortRuns,
+		randomBytes,
+	}
+}
+
 func verifyArraysEqual(a1 []byte, a2 []byte) bool {
 	if len(a1) != len(a2) {
 		return false
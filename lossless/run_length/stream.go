@@ -0,0 +1,135 @@
+package run_length
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The stream is the same (count, byte) pairs RunLengthEncode writes, so without a Flush in the middle a stream is byte
+// for byte what RunLengthEncode gives for all of the data at once and either side can be swapped for the other. The
+// Writer holds on to the run it is in the middle of, a run that carries over from one Write to the next is still one
+// pair. There's no end marker, the stream ends where the pairs do.
+
+type Writer struct {
+	w         io.Writer
+	runByte   byte
+	runLength int
+	out       []byte
+	err       error
+	closed    bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (rw *Writer) Write(p []byte) (int, error) {
+	if rw.closed {
+		return 0, errors.New("run_length: write to closed Writer")
+	}
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	for _, bt := range p {
+		if rw.runLength > 0 && (bt != rw.runByte || rw.runLength == 0xFF) {
+			rw.out = append(rw.out, byte(rw.runLength), rw.runByte)
+			rw.runLength = 0
+		}
+		rw.runByte = bt
+		rw.runLength++
+	}
+	// the run that is still going isn't written, the next Write might add to it
+	if err := rw.writeOut(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out the run in progress. If the next Write keeps the same run going it starts a new pair, so flushing
+// in the middle of a run costs 2 bytes
+func (rw *Writer) Flush() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	if rw.runLength > 0 {
+		rw.out = append(rw.out, byte(rw.runLength), rw.runByte)
+		rw.runLength = 0
+	}
+	return rw.writeOut()
+}
+
+// Close flushes the last run. It does not close the underlying writer
+func (rw *Writer) Close() error {
+	if rw.closed {
+		return rw.err
+	}
+	err := rw.Flush()
+	rw.closed = true
+	return err
+}
+
+func (rw *Writer) writeOut() error {
+	if len(rw.out) == 0 {
+		return nil
+	}
+	_, rw.err = rw.w.Write(rw.out)
+	rw.out = rw.out[:0]
+	return rw.err
+}
+
+type Reader struct {
+	r         *bufio.Reader
+	runByte   byte
+	runLength int
+	err       error
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read fills p from the runs, reading as many pairs as it takes. A stream that ends in the middle of a pair or has a
+// run of 0 gives an ErrCorruptData
+func (rr *Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if rr.runLength == 0 {
+			if rr.err != nil {
+				break
+			}
+			rr.err = rr.readPair()
+			continue
+		}
+		runPart := min(rr.runLength, len(p)-n)
+		for i := 0; i < runPart; i++ {
+			p[n+i] = rr.runByte
+		}
+		n += runPart
+		rr.runLength -= runPart
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, rr.err
+}
+
+func (rr *Reader) readPair() error {
+	count, err := rr.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	bt, err := rr.r.ReadByte()
+	if err == io.EOF {
+		return fmt.Errorf("%w: stream ends in the middle of a pair", ErrCorruptData)
+	}
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: run of length 0", ErrCorruptData)
+	}
+	rr.runByte, rr.runLength = bt, int(count)
+	return nil
+}
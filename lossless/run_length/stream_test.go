@@ -0,0 +1,103 @@
+package run_length
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func getStreamTestData() []byte {
+	rnd := rand.New(rand.NewSource(1))
+	var data []byte
+	for len(data) < 100000 {
+		// runs long enough to go over 255 now and then
+		data = append(data, bytes.Repeat([]byte{byte(rnd.Intn(4))}, 1+rnd.Intn(600))...)
+	}
+	return data
+}
+
+func TestStreamMatchesRunLengthEncode(t *testing.T) {
+	data := getStreamTestData()
+	rnd := rand.New(rand.NewSource(2))
+	var compressed bytes.Buffer
+	rw := NewWriter(&compressed)
+	// uneven writes so runs keep getting split between them
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), rnd.Intn(300))
+		if _, err := rw.Write(rest[:n]); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !bytes.Equal(RunLengthEncode(data), compressed.Bytes()) {
+		t.Fatalf("stream does not match RunLengthEncode")
+	}
+
+	rr := NewReader(bytes.NewReader(compressed.Bytes()))
+	var decompressed bytes.Buffer
+	buf := make([]byte, 77)
+	for {
+		n, err := rr.Read(buf)
+		decompressed.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	}
+	if !bytes.Equal(data, decompressed.Bytes()) {
+		t.Fatalf("decompressed stream does not match original data")
+	}
+}
+
+func TestStreamFlush(t *testing.T) {
+	var compressed bytes.Buffer
+	rw := NewWriter(&compressed)
+	rw.Write([]byte("AAAA"))
+	if compressed.Len() != 0 {
+		t.Fatalf("a run that could still keep going was written out")
+	}
+	if err := rw.Flush(); err != nil || compressed.String() != "\x04A" {
+		t.Fatalf("expected the run to be written on Flush, got %q (err %v)", compressed.String(), err)
+	}
+	rw.Write([]byte("AB"))
+	rw.Close()
+	if compressed.String() != "\x04A\x01A\x01B" {
+		t.Fatalf("expected a new pair after Flush, got %q", compressed.String())
+	}
+	if _, err := rw.Write([]byte("A")); err == nil {
+		t.Fatalf("expected an error writing to a closed Writer")
+	}
+
+	decompressed, err := io.ReadAll(NewReader(&compressed))
+	if err != nil || string(decompressed) != "AAAAAB" {
+		t.Fatalf("expected AAAAAB but got %q (err %v)", decompressed, err)
+	}
+}
+
+func TestStreamCorrupt(t *testing.T) {
+	compressedData := RunLengthEncode(getStreamTestData())
+	for _, corruptData := range [][]byte{
+		compressedData[:len(compressedData)-1],
+		{0x03},
+		{0x03, 'A', 0x00, 'B'},
+	} {
+		if _, err := io.ReadAll(NewReader(bytes.NewReader(corruptData))); !errors.Is(err, ErrCorruptData) {
+			t.Fatalf("expected ErrCorruptData for % X but got %v", corruptData[max(0, len(corruptData)-4):], err)
+		}
+	}
+	if RunLengthDecode(compressedData[:len(compressedData)-1]) != nil {
+		t.Fatalf("expected RunLengthDecode to refuse a cut off pair")
+	}
+
+	decompressed, err := io.ReadAll(NewReader(bytes.NewReader(nil)))
+	if err != nil || len(decompressed) != 0 {
+		t.Fatalf("expected an empty stream to read as nothing, got %v bytes (err %v)", len(decompressed), err)
+	}
+}
@@ -0,0 +1,104 @@
+package run_length
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Zero-run encoding is the run-length step bzip2 does after move-to-front. MTF output is mostly 0s, often in runs far
+// longer than a count byte can hold, and every other byte shows up on its own. So only runs of 0 get counted, and the
+// count is written in bijective base 2 with two digit symbols, RUNA worth 1 and RUNB worth 2 (times 2^i for the i-th
+// digit, least significant first): 1 = A, 2 = B, 3 = AA, 4 = BA, 5 = AB, ... A run of n zeros takes about log2(n)
+// symbols and there's no 0 digit so a run never needs a separate terminator, the next non-zero byte ends it.
+//
+// Since RUNA and RUNB take the first two symbols, every non-zero byte v is written as v+1 and the symbols go from 0 to
+// ZeroRunAlphabetSize-1. That is one past a byte, so they go to an entropy coder through
+// arithmeticcoding.CompressSymbols with ZeroRunAlphabetSize as the alphabet size.
+
+const (
+	RUNA uint16 = 0
+	RUNB uint16 = 1
+	// ZeroRunAlphabetSize is RUNA, RUNB and the 255 non-zero bytes
+	ZeroRunAlphabetSize = 257
+)
+
+func ZeroRunEncode(data []byte) []uint16 {
+	symbols := make([]uint16, 0, len(data))
+	for i := 0; i < len(data); {
+		if data[i] != 0 {
+			symbols = append(symbols, uint16(data[i])+1)
+			i++
+			continue
+		}
+		runLength := 0
+		for ; i < len(data) && data[i] == 0; i++ {
+			runLength++
+		}
+		symbols = appendZeroRun(symbols, uint64(runLength))
+	}
+	return symbols
+}
+
+func ZeroRunDecode(symbols []uint16) ([]byte, error) {
+	return ZeroRunDecodeWithLimit(symbols, DefaultMaxDecodedLen)
+}
+
+// ZeroRunDecodeWithLimit fails with ErrCorruptData instead of decoding more than maxLen bytes. 63 RUNB symbols alone are
+// a run of almost 2^64 zeros
+func ZeroRunDecodeWithLimit(symbols []uint16, maxLen uint64) ([]byte, error) {
+	data := make([]byte, 0, min(uint64(len(symbols)), maxLen))
+	runLength, digitWeight := uint64(0), uint64(1)
+	for i, sym := range symbols {
+		switch {
+		case sym == RUNA || sym == RUNB:
+			// the biggest run that still fits in a uint64 has 63 digits
+			if digitWeight == 0 {
+				return nil, fmt.Errorf("%w: zero run at symbol %v is too long", ErrCorruptData, i)
+			}
+			digit := uint64(sym-RUNA) + 1
+			hi, digitValue := bits.Mul64(digit, digitWeight)
+			var carry uint64
+			runLength, carry = bits.Add64(runLength, digitValue, 0)
+			if hi != 0 || carry != 0 {
+				return nil, fmt.Errorf("%w: zero run at symbol %v is too long", ErrCorruptData, i)
+			}
+			digitWeight <<= 1
+		case sym < ZeroRunAlphabetSize:
+			if runLength >= maxLen-uint64(len(data)) {
+				return nil, fmt.Errorf("%w: symbol %v goes past the limit of %v bytes", ErrCorruptData, i, maxLen)
+			}
+			data = appendZeros(data, runLength)
+			runLength, digitWeight = 0, 1
+			data = append(data, byte(sym-1))
+		default:
+			return nil, fmt.Errorf("%w: symbol %v is outside of the zero-run alphabet", ErrCorruptData, sym)
+		}
+	}
+	if runLength > maxLen-uint64(len(data)) {
+		return nil, fmt.Errorf("%w: the last zero run goes past the limit of %v bytes", ErrCorruptData, maxLen)
+	}
+	return appendZeros(data, runLength), nil
+}
+
+// Helpers
+
+// appendZeroRun writes runLength in bijective base 2, least significant digit first
+func appendZeroRun(symbols []uint16, runLength uint64) []uint16 {
+	for runLength > 0 {
+		if runLength&1 == 1 {
+			symbols = append(symbols, RUNA)
+			runLength = (runLength - 1) / 2
+		} else {
+			symbols = append(symbols, RUNB)
+			runLength = (runLength - 2) / 2
+		}
+	}
+	return symbols
+}
+
+func appendZeros(data []byte, numZeros uint64) []byte {
+	for ; numZeros > 0; numZeros-- {
+		data = append(data, 0)
+	}
+	return data
+}
@@ -0,0 +1,120 @@
+package run_length
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	arithmeticcoding "github.com/ElwinCabrera/go-compression/lossless/arithmetic_coding"
+)
+
+func TestZeroRunEncodeRunLengths(t *testing.T) {
+	A, B := RUNA, RUNB
+	expected := map[int][]uint16{
+		1: {A}, 2: {B}, 3: {A, A}, 4: {B, A}, 5: {A, B}, 6: {B, B}, 7: {A, A, A}, 8: {B, A, A}, 14: {B, B, B},
+	}
+	for runLength, runSymbols := range expected {
+		data := append(bytes.Repeat([]byte{0}, runLength), 7)
+		if symbols := ZeroRunEncode(data); !slices.Equal(append(runSymbols, 8), symbols) {
+			t.Fatalf("run of %v encoded to %v, expected %v", runLength, symbols, append(runSymbols, 8))
+		}
+	}
+}
+
+func TestZeroRunEncode(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// text after a crude sort and move-to-front, mostly 0s with a few small values
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 2000)
+	slices.Sort(text)
+	mtfText := moveToFront(text)
+	randomBytes := make([]byte, 10000)
+	rnd.Read(randomBytes)
+	allBytes := make([]byte, 256)
+	for i := range allBytes {
+		allBytes[i] = byte(i)
+	}
+	for i, data := range [][]byte{
+		{},
+		{0},
+		{255},
+		{0, 0, 255, 0},
+		allBytes,
+		bytes.Repeat([]byte{0}, 1000000),
+		mtfText,
+		randomBytes,
+	} {
+		symbols := ZeroRunEncode(data)
+		for _, sym := range symbols {
+			if sym >= ZeroRunAlphabetSize {
+				t.Fatalf("dataset #%v: symbol %v is outside of the alphabet", i, sym)
+			}
+		}
+		decodedData, err := ZeroRunDecode(symbols)
+		if err != nil || !bytes.Equal(data, decodedData) {
+			t.Fatalf("dataset #%v does not round trip (err %v)", i, err)
+		}
+
+		// the symbols go through the entropy coder as they are, 0xFF included even though it comes out as 256
+		compressedSymbols, ok := arithmeticcoding.CompressSymbols(symbols, ZeroRunAlphabetSize)
+		if !ok {
+			t.Fatalf("dataset #%v: CompressSymbols failed", i)
+		}
+		decompressedSymbols, ok := arithmeticcoding.DecompressSymbols(compressedSymbols)
+		if !ok || !slices.Equal(symbols, decompressedSymbols) {
+			t.Fatalf("dataset #%v does not round trip through CompressSymbols", i)
+		}
+		t.Logf("dataset #%v: %v bytes to %v symbols, %v bytes with CompressSymbols, RunLengthEncode gives %v bytes", i, len(data), len(symbols), len(compressedSymbols), len(RunLengthEncode(data)))
+	}
+
+	// a million zeros is a 19 digit number in bijective base 2
+	if symbols := ZeroRunEncode(bytes.Repeat([]byte{0}, 1000000)); len(symbols) != 19 {
+		t.Fatalf("a million zeros took %v symbols", len(symbols))
+	}
+}
+
+func TestZeroRunDecodeCorrupt(t *testing.T) {
+	if _, err := ZeroRunDecode([]uint16{RUNA, ZeroRunAlphabetSize}); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a symbol outside the alphabet but got %v", err)
+	}
+	tooLong := make([]uint16, 64)
+	for i := range tooLong {
+		tooLong[i] = RUNB
+	}
+	if _, err := ZeroRunDecode(tooLong); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a run that doesn't fit in 64 bits but got %v", err)
+	}
+	// 62 RUNB symbols are a run of 2^63-2 zeros
+	if _, err := ZeroRunDecode(tooLong[:62]); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a run past the limit but got %v", err)
+	}
+	if _, err := ZeroRunDecode(append(tooLong[:62:62], 8)); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData for a run past the limit before a byte but got %v", err)
+	}
+
+	data := []byte{0, 0, 0, 5, 0, 0}
+	if _, err := ZeroRunDecodeWithLimit(ZeroRunEncode(data), uint64(len(data)-1)); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("expected ErrCorruptData with a limit below the real length but got %v", err)
+	}
+	if decodedData, err := ZeroRunDecodeWithLimit(ZeroRunEncode(data), uint64(len(data))); err != nil || !bytes.Equal(data, decodedData) {
+		t.Fatalf("round trip with the limit at the real length failed: %v", err)
+	}
+}
+
+// Helpers
+
+func moveToFront(data []byte) []byte {
+	var order [256]byte
+	for i := range order {
+		order[i] = byte(i)
+	}
+	mtfData := make([]byte, len(data))
+	for i, bt := range data {
+		idx := bytes.IndexByte(order[:], bt)
+		mtfData[i] = byte(idx)
+		copy(order[1:idx+1], order[:idx])
+		order[0] = bt
+	}
+	return mtfData
+}
@@ -0,0 +1,94 @@
+package compression
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type registry struct {
+	mu     sync.RWMutex
+	byName map[string]Codec
+	byID   map[CodecID]Codec
+}
+
+var codecRegistry = &registry{byName: make(map[string]Codec), byID: make(map[CodecID]Codec)}
+
+func init() {
+	builtin := []Codec{
+		HuffmanCodec{},
+		ArithmeticCodec{},
+		RunLengthCodec{},
+		CanonicalHuffmanCodec{},
+		AdaptiveHuffmanCodec{},
+		AdaptiveArithmeticCodec{},
+		PPMCodec{},
+		RANSCodec{},
+		TANSCodec{},
+		PackBitsCodec{},
+		LZ77Codec{},
+	}
+	for _, c := range builtin {
+		if err := Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds a codec to the registry so that it can be looked up by name or ID. Names are case-insensitive.
+// Registering a second codec with a name or ID that is already taken returns ErrDuplicateCodec
+func Register(c Codec) error {
+	name := strings.ToLower(c.Name())
+	if name == "" || c.ID() == CodecUnknown {
+		return fmt.Errorf("%w: codec needs a name and a non-zero ID", ErrUnknownCodec)
+	}
+
+	codecRegistry.mu.Lock()
+	defer codecRegistry.mu.Unlock()
+
+	if _, ok := codecRegistry.byName[name]; ok {
+		return fmt.Errorf("%w: name %q", ErrDuplicateCodec, name)
+	}
+	if _, ok := codecRegistry.byID[c.ID()]; ok {
+		return fmt.Errorf("%w: id %d", ErrDuplicateCodec, uint8(c.ID()))
+	}
+	codecRegistry.byName[name] = c
+	codecRegistry.byID[c.ID()] = c
+	return nil
+}
+
+func Get(name string) (Codec, error) {
+	codecRegistry.mu.RLock()
+	defer codecRegistry.mu.RUnlock()
+
+	c, ok := codecRegistry.byName[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+	return c, nil
+}
+
+func GetByID(id CodecID) (Codec, error) {
+	codecRegistry.mu.RLock()
+	defer codecRegistry.mu.RUnlock()
+
+	c, ok := codecRegistry.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d", ErrUnknownCodec, uint8(id))
+	}
+	return c, nil
+}
+
+// Codecs returns every registered codec sorted by ID
+func Codecs() []Codec {
+	codecRegistry.mu.RLock()
+	defer codecRegistry.mu.RUnlock()
+
+	codecs := make([]Codec, 0, len(codecRegistry.byID))
+	for _, c := range codecRegistry.byID {
+		codecs = append(codecs, c)
+	}
+	sort.Slice(codecs, func(i, j int) bool { return codecs[i].ID() < codecs[j].ID() })
+	return codecs
+}